@@ -20,25 +20,39 @@ func main() {
 		log.Fatal("DATABASE_URL environment variable is required")
 	}
 
-	lb, err := leaderboard.NewLeaderboard(dsn)
+	boards, err := leaderboard.NewPostgresBoards(dsn)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
-	defer lb.Close()
+	defer boards.Close()
+
+	// Keep the in-memory rank caches honest against the database
+	go boards.WatchConsistency(time.Minute)
+	// Archive daily, weekly and monthly gain windows as they close
+	go boards.WatchWindows(time.Minute)
+
+	lb, err := boards.Board(leaderboard.DefaultBoard)
+	if err != nil {
+		log.Fatalf("Failed to open default board: %v", err)
+	}
 
 	// Seed initial data if requested via env or just empty start
 	// For dev, let's seed 1000 users to start with if empty
 	if lb.Count() == 0 {
 		log.Println("Seeding initial 10,000 users...")
-		lb.Seed(10000, true)
-		log.Printf("Seeding complete. Total users: %d", lb.Count())
+		result, err := lb.Seed(leaderboard.SeedOptions{Count: 10000, Clear: true})
+		if err != nil {
+			log.Printf("Seeding failed: %v", err)
+		} else {
+			log.Printf("Seeding complete (seed %d). Total users: %d", result.RandomSeed, lb.Count())
+		}
 	}
 
-	// 2. Initialize Simulator
+	// 2. Initialize Simulator (drives the default board)
 	sim := simulator.NewSimulator(lb)
 
 	// 3. Initialize API Handler
-	handler := api.NewHandler(lb, sim)
+	handler := api.NewHandler(boards, sim)
 	router := api.NewHandlerWithMiddleware(handler)
 
 	// 4. Start Server
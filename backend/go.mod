@@ -4,5 +4,6 @@ go 1.22
 
 require (
 	github.com/brianvoe/gofakeit/v6 v6.28.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/lib/pq v1.10.9
 )
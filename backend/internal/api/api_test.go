@@ -0,0 +1,250 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"goleaderboard/internal/leaderboard"
+	"goleaderboard/internal/simulator"
+)
+
+// newTestServer serves the API over fresh in-memory boards
+func newTestServer(t *testing.T) (*httptest.Server, *leaderboard.MemoryBoards) {
+	t.Helper()
+	boards := leaderboard.NewMemoryBoards()
+	lb, err := boards.Board(leaderboard.DefaultBoard)
+	if err != nil {
+		t.Fatalf("Board failed: %v", err)
+	}
+	srv := httptest.NewServer(NewHandlerWithMiddleware(NewHandler(boards, simulator.NewSimulator(lb))))
+	t.Cleanup(srv.Close)
+	return srv, boards
+}
+
+// do sends a request with an optional body and returns the response
+func do(t *testing.T, srv *httptest.Server, method, path, contentType, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest failed: %v", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestWriteUserError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{leaderboard.ErrUserNotFound, http.StatusNotFound},
+		{leaderboard.ErrUserExists, http.StatusConflict},
+		{fmt.Errorf("%w: too high", leaderboard.ErrInvalidRating), http.StatusBadRequest},
+		{leaderboard.ErrInvalidPolicy, http.StatusBadRequest},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		writeUserError(rec, tt.err, "alice")
+		if rec.Code != tt.want {
+			t.Errorf("writeUserError(%v) status = %d; want %d", tt.err, rec.Code, tt.want)
+		}
+		var body ErrorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("writeUserError(%v) body is not JSON: %v", tt.err, err)
+		}
+		if body.Error != tt.err.Error() || body.Username != "alice" {
+			t.Errorf("writeUserError(%v) body = %+v", tt.err, body)
+		}
+	}
+}
+
+func TestParseTimeParam(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in       string
+		from, to time.Time
+	}{
+		{"", time.Time{}, time.Time{}},
+		{"2024-03-01", day, day.AddDate(0, 0, 1)},
+		{"2024-03-01T12:30:00Z", day.Add(12*time.Hour + 30*time.Minute), day.Add(12*time.Hour + 30*time.Minute)},
+		{"1709251200", day, day},
+	}
+	for _, tt := range tests {
+		from, err := parseTimeParam(tt.in)
+		if err != nil || !from.Equal(tt.from) {
+			t.Errorf("parseTimeParam(%q) = %v, %v; want %v", tt.in, from, err, tt.from)
+		}
+		to, err := parseEndParam(tt.in)
+		if err != nil || !to.Equal(tt.to) {
+			t.Errorf("parseEndParam(%q) = %v, %v; want %v", tt.in, to, err, tt.to)
+		}
+	}
+
+	if _, err := parseTimeParam("yesterday"); err == nil {
+		t.Error("parseTimeParam accepted \"yesterday\"")
+	}
+}
+
+func TestHandler_ErrorsAreJSON(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	tests := []struct {
+		method, path, body string
+		status             int
+	}{
+		{"GET", "/api/user/ghost", "", http.StatusNotFound},
+		{"GET", "/api/user/ghost/history", "", http.StatusNotImplemented},
+		{"GET", "/api/boards/missing/leaderboard", "", http.StatusNotFound},
+		{"POST", "/api/boards", "{", http.StatusBadRequest},
+		{"POST", "/api/boards", `{"name": "Bad Name!"}`, http.StatusBadRequest},
+		{"POST", "/api/boards", `{"name": "times", "decimals": 12}`, http.StatusBadRequest},
+		{"DELETE", "/api/boards/missing", "", http.StatusNotFound},
+		{"POST", "/api/users", `{"username": "alice"}`, http.StatusBadRequest},
+		{"GET", "/api/cutoff", "", http.StatusBadRequest},
+		{"GET", "/api/search?q=a", "", http.StatusBadRequest},
+		{"POST", "/api/matches", "not json", http.StatusBadRequest},
+		{"POST", "/api/seed", "{", http.StatusBadRequest},
+		{"POST", "/api/users/bulk?mode=merge", "[]", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		resp := do(t, srv, tt.method, tt.path, "application/json", tt.body)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s %s status = %d; want %d", tt.method, tt.path, resp.StatusCode, tt.status)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s %s Content-Type = %q; want application/json", tt.method, tt.path, ct)
+		}
+		var body ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Error == "" {
+			t.Errorf("%s %s body = %+v, %v; want an ErrorResponse", tt.method, tt.path, body, err)
+		}
+	}
+}
+
+func TestHandler_Users(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	resp := do(t, srv, "POST", "/api/users", "application/json", `{"username": "alice", "rating": 1500}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create status = %d; want 201", resp.StatusCode)
+	}
+	resp = do(t, srv, "POST", "/api/users", "application/json", `{"username": "alice", "rating": 1500}`)
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("duplicate create status = %d; want 409", resp.StatusCode)
+	}
+
+	resp = do(t, srv, "GET", "/api/user/alice", "", "")
+	var user UserResponse
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		t.Fatalf("decoding user failed: %v", err)
+	}
+	if user.Username != "alice" || user.Rating != 1500 || user.Rank != 1 {
+		t.Errorf("user = %+v; want alice at 1500, rank 1", user)
+	}
+}
+
+func TestHandler_DecimalBoard(t *testing.T) {
+	srv, boards := newTestServer(t)
+
+	resp := do(t, srv, "POST", "/api/boards", "application/json", `{"name": "times", "order": "asc", "min_rating": 0, "max_rating": 3600000, "decimals": 3}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create board status = %d; want 201", resp.StatusCode)
+	}
+	var cfg leaderboard.BoardConfig
+	if err := json.NewDecoder(resp.Body).Decode(&cfg); err != nil || cfg.Decimals != 3 {
+		t.Fatalf("created board = %+v, %v; want 3 decimals", cfg, err)
+	}
+
+	// 61.25s is submitted as the integer score 61250
+	resp = do(t, srv, "POST", "/api/boards/times/user/runner/scores", "application/json", `{"score": 61250}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("submit status = %d; want 201", resp.StatusCode)
+	}
+	lb, _ := boards.Board("times")
+	if u, err := lb.GetUserRank("runner"); err != nil || u.Rating != 61250 {
+		t.Errorf("runner = %+v, %v; want 61250", u, err)
+	}
+}
+
+func TestDecodeBulkRows(t *testing.T) {
+	for _, body := range []string{
+		`[{"username": "alice", "rating": 1500}, {"username": "bob"}, {"username": "carol", "rating": "high"}]`,
+		"{\"username\": \"alice\", \"rating\": 1500}\n{\"username\": \"bob\", \"rating\": null}\n\n{\"username\": \"carol\", \"rating\": 15.5}\n",
+	} {
+		rows, err := decodeBulkRows(strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("decodeBulkRows(%q) failed: %v", body, err)
+		}
+		if len(rows) != 3 {
+			t.Fatalf("decodeBulkRows(%q) = %d rows; want 3", body, len(rows))
+		}
+		if rows[0].Rating == nil || *rows[0].Rating != 1500 || rows[1].Rating != nil || rows[1].RatingError != "" {
+			t.Errorf("decodeBulkRows(%q) = %+v", body, rows)
+		}
+		if rows[2].Rating != nil || rows[2].RatingError == "" {
+			t.Errorf("decodeBulkRows(%q) carol = %+v; want a rating error", body, rows[2])
+		}
+	}
+
+	rows, _ := decodeBulkRows(strings.NewReader("\n{\"username\": \"a\", \"rating\": 1}\n{\"username\": \"b\", \"rating\": \"x\"}\n"))
+	if want := `row 1: rating "x" is not an integer`; rows[1].RatingError != want {
+		t.Errorf("RatingError = %q; want %q", rows[1].RatingError, want)
+	}
+
+	if _, err := decodeBulkRows(strings.NewReader(`[{"username": 1}]`)); err == nil {
+		t.Error("decodeBulkRows accepted a malformed row")
+	}
+}
+
+func TestDecodeCSVRows(t *testing.T) {
+	rows, err := decodeCSVRows(strings.NewReader("rank,Rating,username\n1,1500,alice\n2,,bob\n3,abc,carol\n"))
+	if err != nil {
+		t.Fatalf("decodeCSVRows failed: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("decodeCSVRows = %d rows; want 3", len(rows))
+	}
+	if rows[0].Username != "alice" || rows[0].Rating == nil || *rows[0].Rating != 1500 {
+		t.Errorf("alice = %+v", rows[0])
+	}
+	if rows[1].Rating != nil || rows[1].RatingError != "" {
+		t.Errorf("bob = %+v; want no rating", rows[1])
+	}
+	if want := `line 4: rating "abc" is not an integer`; rows[2].RatingError != want {
+		t.Errorf("carol RatingError = %q; want %q", rows[2].RatingError, want)
+	}
+
+	if _, err := decodeCSVRows(strings.NewReader("name,score\nalice,1500\n")); err == nil {
+		t.Error("decodeCSVRows accepted a header without username and rating")
+	}
+}
+
+func TestHandler_BulkInvalidRating(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	resp := do(t, srv, "POST", "/api/users/bulk", "application/json", `[{"username": "alice", "rating": 1500}, {"username": "bob", "rating": "abc"}]`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("bulk status = %d; want 200", resp.StatusCode)
+	}
+	var body BulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding bulk response failed: %v", err)
+	}
+	if body.Results[0].Status != leaderboard.BulkCreated || body.Results[1].Status != leaderboard.BulkInvalidRating {
+		t.Errorf("bulk results = %+v; want created, invalid_rating", body.Results)
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"goleaderboard/internal/leaderboard"
+)
+
+// CreateBoardRequest represents the create board body.
+// Bounds default to the global board's when both are omitted.
+type CreateBoardRequest struct {
+	Name         string  `json:"name"`
+	MinRating    int64   `json:"min_rating"`
+	MaxRating    int64   `json:"max_rating"`
+	RatingSystem string  `json:"rating_system"` // "elo" (default) or "glicko2"
+	KFactor      float64 `json:"k_factor"`
+	RankingMode  string  `json:"ranking_mode"` // "competition" (default), "dense" or "ordinal"
+	TieBreak     string  `json:"tie_break"`    // "username" (default), "achieved" or "created"
+	Order        string  `json:"order"`        // "desc" (default) or "asc" when lower is better
+	Decimals     int     `json:"decimals"`     // fractional digits of the integer scores
+}
+
+func (h *Handler) ListBoards(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.boards.ListBoards())
+}
+
+func (h *Handler) GetBoard(w http.ResponseWriter, r *http.Request) {
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"board": lb.Config(),
+		"stats": lb.GetStats(),
+	})
+}
+
+func (h *Handler) CreateBoard(w http.ResponseWriter, r *http.Request) {
+	var req CreateBoardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if req.MinRating == 0 && req.MaxRating == 0 {
+		req.MinRating = leaderboard.MinRating
+		req.MaxRating = leaderboard.MaxRating
+	}
+
+	cfg, err := h.boards.CreateBoard(leaderboard.BoardConfig{
+		Name:         req.Name,
+		MinRating:    req.MinRating,
+		MaxRating:    req.MaxRating,
+		RatingSystem: req.RatingSystem,
+		KFactor:      req.KFactor,
+		RankingMode:  req.RankingMode,
+		TieBreak:     req.TieBreak,
+		Order:        req.Order,
+		Decimals:     req.Decimals,
+	})
+	switch {
+	case errors.Is(err, leaderboard.ErrInvalidBoard):
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error(), Board: req.Name})
+		return
+	case errors.Is(err, leaderboard.ErrBoardExists):
+		writeError(w, http.StatusConflict, ErrorResponse{Error: err.Error(), Board: req.Name})
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error(), Board: req.Name})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(cfg)
+}
+
+func (h *Handler) DeleteBoard(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("board")
+	err := h.boards.DeleteBoard(name)
+	switch {
+	case errors.Is(err, leaderboard.ErrInvalidBoard):
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error(), Board: name})
+		return
+	case errors.Is(err, leaderboard.ErrBoardNotFound):
+		writeError(w, http.StatusNotFound, ErrorResponse{Error: err.Error(), Board: name})
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error(), Board: name})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
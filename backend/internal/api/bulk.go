@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"goleaderboard/internal/leaderboard"
+)
+
+// maxBulkRows caps the rows of one bulk request
+const maxBulkRows = 50_000
+
+// BulkResponse reports every row's outcome and how many rows ended in
+// each status
+type BulkResponse struct {
+	Mode    string                   `json:"mode"`
+	Results []leaderboard.BulkResult `json:"results"`
+	Summary map[string]int           `json:"summary"`
+}
+
+// bulkRowJSON is a bulk row as sent, with the rating left raw so that a
+// rating which is not an integer fails only its own row
+type bulkRowJSON struct {
+	Username string          `json:"username"`
+	Rating   json.RawMessage `json:"rating"`
+}
+
+// decodeBulkRows reads a JSON array of rows, or newline-delimited JSON
+// objects when the body does not start with '['. A rating that is not an
+// integer fails only its row.
+func decodeBulkRows(body io.Reader) ([]leaderboard.BulkRow, error) {
+	br := bufio.NewReader(body)
+	var first byte
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			first = b
+			br.UnreadByte()
+			break
+		}
+	}
+
+	dec := json.NewDecoder(br)
+	var rows []leaderboard.BulkRow
+	if first == '[' {
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+	for dec.More() {
+		if len(rows) == maxBulkRows {
+			return nil, fmt.Errorf("at most %d rows per request", maxBulkRows)
+		}
+		var raw bulkRowJSON
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("row %d: %w", len(rows), err)
+		}
+		row := leaderboard.BulkRow{Username: raw.Username}
+		if len(raw.Rating) > 0 && string(raw.Rating) != "null" {
+			if rating, err := strconv.ParseInt(string(raw.Rating), 10, 64); err != nil {
+				row.RatingError = fmt.Sprintf("row %d: rating %s is not an integer", len(rows), raw.Rating)
+			} else {
+				row.Rating = &rating
+			}
+		}
+		rows = append(rows, row)
+	}
+	if first == '[' {
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// BulkUsers creates or updates many users in one request. The body is a
+// JSON array or NDJSON of {"username", "rating"} rows; ?mode= picks
+// upsert (default), insert or update. Rows that cannot be applied are
+// reported in the results without failing the request.
+func (h *Handler) BulkUsers(w http.ResponseWriter, r *http.Request) {
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = leaderboard.BulkUpsert
+	}
+	rows, err := decodeBulkRows(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	results, err := lb.BulkWrite(rows, mode)
+	if errors.Is(err, leaderboard.ErrInvalidBulk) {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	resp := BulkResponse{Mode: mode, Results: results, Summary: make(map[string]int)}
+	if resp.Results == nil {
+		resp.Results = []leaderboard.BulkResult{}
+	}
+	for _, res := range results {
+		resp.Summary[res.Status]++
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"goleaderboard/internal/leaderboard"
+)
+
+// exportFlushEvery is how many rows an export writes between flushes
+const exportFlushEvery = 1000
+
+// Export streams every user of the board with rating and rank, in rank
+// order, as ?format=csv (default) or ?format=ndjson
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+
+	var write func(leaderboard.RankedUser) error
+	var flush func() error
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		write = func(u leaderboard.RankedUser) error {
+			return cw.Write([]string{strconv.Itoa(u.Rank), u.Username, strconv.FormatInt(u.Rating, 10)})
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		defer cw.Flush()
+		// Buffered by the csv writer until the first flush
+		cw.Write([]string{"rank", "username", "rating"})
+	case "ndjson":
+		enc := json.NewEncoder(w)
+		write = func(u leaderboard.RankedUser) error { return enc.Encode(u) }
+		flush = func() error { return nil }
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	default:
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "format must be csv or ndjson"})
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", lb.Config().Name+"-leaderboard."+format))
+
+	rc := http.NewResponseController(w)
+	rows := 0
+	err := lb.Export(func(u leaderboard.RankedUser) error {
+		if err := write(u); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushEvery == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		// The status line is long gone; cut the stream short
+		log.Printf("Export of board %s failed after %d rows: %v", lb.Config().Name, rows, err)
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"goleaderboard/internal/leaderboard"
+)
+
+// FriendsLeaderboardResponse ranks a user's friends on one board
+type FriendsLeaderboardResponse struct {
+	Username string                   `json:"username"`
+	Board    string                   `json:"board"`
+	Users    []leaderboard.FriendRank `json:"users"`
+}
+
+// friends returns the social graph, or answers 501 if the storage engine
+// has none
+func (h *Handler) friends(w http.ResponseWriter) (leaderboard.Friends, bool) {
+	f, ok := h.boards.(leaderboard.Friends)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, ErrorResponse{Error: "friends are not supported by this storage engine"})
+	}
+	return f, ok
+}
+
+func writeFriendError(w http.ResponseWriter, err error, username string) {
+	switch {
+	case errors.Is(err, leaderboard.ErrInvalidFriend):
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error(), Username: username})
+	case errors.Is(err, leaderboard.ErrFriendNotFound):
+		writeError(w, http.StatusNotFound, ErrorResponse{Error: err.Error(), Username: username})
+	default:
+		writeUserError(w, err, username)
+	}
+}
+
+func (h *Handler) ListFriends(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.friends(w)
+	if !ok {
+		return
+	}
+
+	username := r.PathValue("username")
+	friends, err := f.ListFriends(username)
+	if err != nil {
+		writeFriendError(w, err, username)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(friends)
+}
+
+// AddFriend makes the user follow {friend}; repeating it is harmless
+func (h *Handler) AddFriend(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.friends(w)
+	if !ok {
+		return
+	}
+
+	username := r.PathValue("username")
+	if err := f.AddFriend(username, r.PathValue("friend")); err != nil {
+		writeFriendError(w, err, username)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) RemoveFriend(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.friends(w)
+	if !ok {
+		return
+	}
+
+	username := r.PathValue("username")
+	if err := f.RemoveFriend(username, r.PathValue("friend")); err != nil {
+		writeFriendError(w, err, username)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetFriendsLeaderboard ranks the user and their friends against each
+// other, alongside their global ranks
+func (h *Handler) GetFriendsLeaderboard(w http.ResponseWriter, r *http.Request) {
+	fs, ok := storeAs[leaderboard.FriendStore](h, w, r, "friends")
+	if !ok {
+		return
+	}
+
+	username := r.PathValue("username")
+	users, err := fs.FriendsLeaderboard(username)
+	if err != nil {
+		writeUserError(w, err, username)
+		return
+	}
+
+	board := r.PathValue("board")
+	if board == "" {
+		board = leaderboard.DefaultBoard
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(FriendsLeaderboardResponse{
+		Username: username,
+		Board:    board,
+		Users:    users,
+	})
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"goleaderboard/internal/leaderboard"
+)
+
+// WindowLeaderboardResponse represents a paginated leaderboard of rating
+// gained within a window
+type WindowLeaderboardResponse struct {
+	Window     leaderboard.Window       `json:"window"`
+	Users      []leaderboard.WindowRank `json:"users"`
+	Pagination PaginationInfo           `json:"pagination"`
+}
+
+// getWindowLeaderboard serves GET /leaderboard?window=daily|weekly|monthly.
+// The current calendar window is served unless ?at= picks an earlier one
+// or ?rolling=true asks for the span ending now.
+func (h *Handler) getWindowLeaderboard(w http.ResponseWriter, r *http.Request, lb leaderboard.Store, kind string) {
+	ws, ok := lb.(leaderboard.WindowStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, ErrorResponse{Error: "windowed leaderboards are not supported by this storage engine"})
+		return
+	}
+	if r.URL.Query().Get("cursor") != "" {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "cursor pagination is not supported for windows; use offset"})
+		return
+	}
+
+	at, err := parseTimeParam(r.URL.Query().Get("at"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "at: " + err.Error()})
+		return
+	}
+	rolling := r.URL.Query().Get("rolling") == "true"
+	if at.IsZero() {
+		at = time.Now()
+	} else if rolling {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "at cannot be combined with rolling"})
+		return
+	}
+
+	var window leaderboard.Window
+	if rolling {
+		window, err = leaderboard.RollingWindow(kind, at)
+	} else {
+		window, err = leaderboard.CalendarWindow(kind, at)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	limit, offset := pageParams(r)
+	users, total, err := ws.WindowLeaderboard(window, limit, offset)
+	switch {
+	case errors.Is(err, leaderboard.ErrWindowNotFound):
+		writeError(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WindowLeaderboardResponse{
+		Window: window,
+		Users:  users,
+		Pagination: PaginationInfo{
+			Offset:  &offset,
+			Limit:   limit,
+			Total:   total,
+			HasMore: offset+len(users) < total,
+		},
+	})
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"goleaderboard/internal/leaderboard"
+)
+
+// CreateGroupRequest represents the create group body
+type CreateGroupRequest struct {
+	Name string `json:"name"`
+}
+
+// GroupLeaderboardResponse represents a paginated group leaderboard
+type GroupLeaderboardResponse struct {
+	Groups     []leaderboard.RankedGroup  `json:"groups"`
+	Aggregate  leaderboard.GroupAggregate `json:"aggregate"`
+	Pagination PaginationInfo             `json:"pagination"`
+}
+
+// GroupResponse represents a single group lookup with a page of its
+// members ranked within the group
+type GroupResponse struct {
+	leaderboard.RankedGroup
+	Aggregate  leaderboard.GroupAggregate `json:"aggregate"`
+	Members    []leaderboard.MemberRank   `json:"members"`
+	Pagination PaginationInfo             `json:"pagination"`
+}
+
+// groups returns the group registry, or answers 501 if the storage engine
+// has none
+func (h *Handler) groups(w http.ResponseWriter) (leaderboard.Groups, bool) {
+	g, ok := h.boards.(leaderboard.Groups)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, ErrorResponse{Error: "groups are not supported by this storage engine"})
+	}
+	return g, ok
+}
+
+func writeGroupError(w http.ResponseWriter, err error, group, username string) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, leaderboard.ErrInvalidGroup):
+		status = http.StatusBadRequest
+	case errors.Is(err, leaderboard.ErrGroupNotFound), errors.Is(err, leaderboard.ErrNotGroupMember),
+		errors.Is(err, leaderboard.ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, leaderboard.ErrGroupExists), errors.Is(err, leaderboard.ErrAlreadyInGroup):
+		status = http.StatusConflict
+	}
+	writeError(w, status, ErrorResponse{Error: err.Error(), Group: group, Username: username})
+}
+
+// groupAggregate reads ?aggregate=sum|avg|topk and ?k=
+func groupAggregate(r *http.Request) leaderboard.GroupAggregate {
+	k, _ := strconv.Atoi(r.URL.Query().Get("k"))
+	return leaderboard.GroupAggregate{Mode: r.URL.Query().Get("aggregate"), K: k}.WithDefaults()
+}
+
+// pageParams reads ?limit= and ?offset= with the leaderboard's defaults
+func pageParams(r *http.Request) (limit, offset int) {
+	limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+func (h *Handler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	g, ok := h.groups(w)
+	if !ok {
+		return
+	}
+
+	groups, err := g.ListGroups()
+	if err != nil {
+		writeGroupError(w, err, "", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(groups)
+}
+
+func (h *Handler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	g, ok := h.groups(w)
+	if !ok {
+		return
+	}
+
+	var req CreateGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	group, err := g.CreateGroup(req.Name)
+	if err != nil {
+		writeGroupError(w, err, req.Name, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(group)
+}
+
+func (h *Handler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	g, ok := h.groups(w)
+	if !ok {
+		return
+	}
+
+	name := r.PathValue("group")
+	if err := g.DeleteGroup(name); err != nil {
+		writeGroupError(w, err, name, "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JoinGroup adds {username} to {group}; repeating it is harmless
+func (h *Handler) JoinGroup(w http.ResponseWriter, r *http.Request) {
+	g, ok := h.groups(w)
+	if !ok {
+		return
+	}
+
+	name, username := r.PathValue("group"), r.PathValue("username")
+	if err := g.JoinGroup(name, username); err != nil {
+		writeGroupError(w, err, name, username)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) LeaveGroup(w http.ResponseWriter, r *http.Request) {
+	g, ok := h.groups(w)
+	if !ok {
+		return
+	}
+
+	name, username := r.PathValue("group"), r.PathValue("username")
+	if err := g.LeaveGroup(name, username); err != nil {
+		writeGroupError(w, err, name, username)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetGroupLeaderboard mirrors GetLeaderboard for groups, ranked by the
+// aggregate rating of their members on the board
+func (h *Handler) GetGroupLeaderboard(w http.ResponseWriter, r *http.Request) {
+	gs, ok := storeAs[leaderboard.GroupStore](h, w, r, "groups")
+	if !ok {
+		return
+	}
+
+	limit, offset := pageParams(r)
+	agg := groupAggregate(r)
+	groups, total, err := gs.GroupLeaderboard(agg, limit, offset)
+	if err != nil {
+		writeGroupError(w, err, "", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(GroupLeaderboardResponse{
+		Groups:    groups,
+		Aggregate: agg,
+		Pagination: PaginationInfo{
+			Offset:  &offset,
+			Limit:   limit,
+			Total:   total,
+			HasMore: offset+len(groups) < total,
+		},
+	})
+}
+
+// GetGroup mirrors GetUser for groups, with a page of the group's members
+func (h *Handler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	gs, ok := storeAs[leaderboard.GroupStore](h, w, r, "groups")
+	if !ok {
+		return
+	}
+
+	name := r.PathValue("group")
+	agg := groupAggregate(r)
+	group, err := gs.GetGroupRank(name, agg)
+	if err != nil {
+		writeGroupError(w, err, name, "")
+		return
+	}
+
+	limit, offset := pageParams(r)
+	members, err := gs.GroupMembers(name, limit, offset)
+	if err != nil {
+		writeGroupError(w, err, name, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(GroupResponse{
+		RankedGroup: *group,
+		Aggregate:   agg,
+		Members:     members,
+		Pagination: PaginationInfo{
+			Offset:  &offset,
+			Limit:   limit,
+			Total:   group.Members,
+			HasMore: offset+len(members) < group.Members,
+		},
+	})
+}
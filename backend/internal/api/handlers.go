@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -11,21 +12,81 @@ import (
 )
 
 type Handler struct {
-	lb  *leaderboard.Leaderboard
-	sim *simulator.Simulator
+	boards leaderboard.Boards
+	sim    *simulator.Simulator
 }
 
-func NewHandler(lb *leaderboard.Leaderboard, sim *simulator.Simulator) *Handler {
+func NewHandler(boards leaderboard.Boards, sim *simulator.Simulator) *Handler {
 	return &Handler{
-		lb:  lb,
-		sim: sim,
+		boards: boards,
+		sim:    sim,
 	}
 }
 
-// SeedRequest represents the seed endpoint body
+// store resolves the {board} path value, or the default board for the
+// un-prefixed routes. It writes the error response and returns false if
+// the board cannot be served.
+func (h *Handler) store(w http.ResponseWriter, r *http.Request) (leaderboard.Store, bool) {
+	name := r.PathValue("board")
+	if name == "" {
+		name = leaderboard.DefaultBoard
+	}
+
+	lb, err := h.boards.Board(name)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, leaderboard.ErrBoardNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, ErrorResponse{Error: err.Error(), Board: name})
+		return nil, false
+	}
+	return lb, true
+}
+
+// ErrorResponse is the JSON error body. Board, Group and Username name
+// the resource the error is about, when there is one.
+type ErrorResponse struct {
+	Error    string `json:"error"`
+	Board    string `json:"board,omitempty"`
+	Group    string `json:"group,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, resp ErrorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// storeAs resolves the board like store and asserts that its engine
+// supports an optional feature interface, answering 501 if it does not
+func storeAs[T any](h *Handler, w http.ResponseWriter, r *http.Request, feature string) (T, bool) {
+	var zero T
+	lb, ok := h.store(w, r)
+	if !ok {
+		return zero, false
+	}
+
+	fs, ok := lb.(T)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, ErrorResponse{Error: feature + " are not supported by this storage engine"})
+		return zero, false
+	}
+	return fs, true
+}
+
+// SeedRequest represents the seed endpoint body. Sending the seed a
+// response reports back repeats the same users.
 type SeedRequest struct {
-	Count         int  `json:"count"`
-	ClearExisting bool `json:"clear_existing"`
+	Count           int      `json:"count"`
+	ClearExisting   bool     `json:"clear_existing"`
+	Seed            int64    `json:"seed"`             // 0 picks one
+	Distribution    string   `json:"distribution"`     // "uniform" (default), "normal" or "power_law"
+	Mean            *float64 `json:"mean"`             // normal only; defaults to mid-range
+	StdDev          float64  `json:"stddev"`           // normal only; defaults to a sixth of the range
+	Exponent        float64  `json:"exponent"`         // power_law only; defaults to 1.5
+	UsernamePattern string   `json:"username_pattern"` // {i}, {name} and {n} placeholders; defaults to "{name}_{n}"
 }
 
 // LeaderboardResponse represents paginated leaderboard
@@ -34,32 +95,73 @@ type LeaderboardResponse struct {
 	Pagination PaginationInfo           `json:"pagination"`
 }
 
+// PaginationInfo describes a page. Pages can be walked by offset, or with
+// the opaque cursors, which stay fast however deep the page is. Offset is
+// omitted from pages fetched by cursor.
 type PaginationInfo struct {
-	Offset  int  `json:"offset"`
-	Limit   int  `json:"limit"`
-	Total   int  `json:"total"`
-	HasMore bool `json:"has_more"`
+	Offset     *int   `json:"offset,omitempty"`
+	Limit      int    `json:"limit"`
+	Total      int    `json:"total"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// encodeCursor returns the token for c, or "" when there is no such page
+func encodeCursor(c *leaderboard.Cursor) string {
+	if c == nil {
+		return ""
+	}
+	return c.Encode()
 }
 
 // UserResponse represents single user lookup
 type UserResponse struct {
 	Username   string  `json:"username"`
-	Rating     int     `json:"rating"`
+	Rating     int64   `json:"rating"`
 	Rank       int     `json:"rank"`
 	Percentile float64 `json:"percentile"`
 }
 
+func newUserResponse(lb leaderboard.Store, ranked *leaderboard.RankedUser) UserResponse {
+	total := lb.Count()
+	percentile := 0.0
+	if total > 0 {
+		percentile = 100.0 * float64(total-ranked.Rank+1) / float64(total)
+	}
+	return UserResponse{
+		Username:   ranked.Username,
+		Rating:     ranked.Rating,
+		Rank:       ranked.Rank,
+		Percentile: percentile,
+	}
+}
+
+// CutoffResponse reports the rating needed to reach a rank or percentile
+type CutoffResponse struct {
+	Rank       int     `json:"rank"`
+	Percentile float64 `json:"percentile"`
+	Rating     int64   `json:"rating"`
+	Total      int     `json:"total"`
+}
+
 // SimRequest for starting simulation
 type SimRequest struct {
-	UpdatesPerSecond int `json:"updates_per_second"`
-	DurationSeconds  int `json:"duration_seconds"`
-	RatingChangeMax  int `json:"rating_change_max"`
+	UpdatesPerSecond int   `json:"updates_per_second"`
+	DurationSeconds  int   `json:"duration_seconds"`
+	RatingChangeMax  int   `json:"rating_change_max"`
+	Seed             int64 `json:"seed"` // repeats a run's sequence of updates
 }
 
 func (h *Handler) Seed(w http.ResponseWriter, r *http.Request) {
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
 	var req SeedRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 		return
 	}
 
@@ -68,42 +170,107 @@ func (h *Handler) Seed(w http.ResponseWriter, r *http.Request) {
 	}
 
 	start := time.Now()
-	h.lb.Seed(req.Count, req.ClearExisting)
+	result, err := lb.Seed(leaderboard.SeedOptions{
+		Count:           req.Count,
+		Clear:           req.ClearExisting,
+		RandomSeed:      req.Seed,
+		Distribution:    req.Distribution,
+		Mean:            req.Mean,
+		StdDev:          req.StdDev,
+		Exponent:        req.Exponent,
+		UsernamePattern: req.UsernamePattern,
+	})
 	duration := time.Since(start)
+	switch {
+	case errors.Is(err, leaderboard.ErrInvalidSeed):
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	case err != nil && result == nil:
+		writeError(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
 
-	stats := h.lb.GetStats()
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success":       true,
-		"users_created": req.Count,
+	resp := map[string]interface{}{
+		"success":       err == nil,
+		"seed":          result.RandomSeed,
+		"users_created": result.Created,
+		"skipped":       result.Skipped,
 		"duration_ms":   duration.Milliseconds(),
-		"stats":         stats,
-	})
+		"stats":         lb.GetStats(),
+	}
+	status := http.StatusOK
+	if err != nil {
+		// Batches before the failure stay committed
+		resp["error"] = err.Error()
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
 }
 
 func (h *Handler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	if kind := r.URL.Query().Get("window"); kind != "" {
+		h.getWindowLeaderboard(w, r, lb, kind)
+		return
+	}
 
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
 	if limit <= 0 || limit > 100 {
 		limit = 50
 	}
-	if offset < 0 {
-		offset = 0
-	}
 
-	users := h.lb.GetTopN(limit, offset)
-	total := h.lb.Count()
-
-	resp := LeaderboardResponse{
-		Users: users,
-		Pagination: PaginationInfo{
-			Offset:  offset,
-			Limit:   limit,
-			Total:   total,
-			HasMore: offset+len(users) < total,
-		},
+	var resp LeaderboardResponse
+	if token := r.URL.Query().Get("cursor"); token != "" {
+		cursor, err := leaderboard.DecodeCursor(token)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		page, err := lb.GetPage(cursor, limit)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+			return
+		}
+		resp = LeaderboardResponse{
+			Users: page.Users,
+			Pagination: PaginationInfo{
+				Limit:      limit,
+				Total:      lb.Count(),
+				HasMore:    page.HasNext,
+				NextCursor: encodeCursor(page.NextCursor()),
+				PrevCursor: encodeCursor(page.PrevCursor()),
+			},
+		}
+	} else {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if offset < 0 {
+			offset = 0
+		}
+		users := lb.GetTopN(limit, offset)
+		total := lb.Count()
+
+		// Offset pages hand out cursors too, so clients can switch over
+		page := leaderboard.Page{Users: users, HasPrev: offset > 0, HasNext: offset+len(users) < total}
+		resp = LeaderboardResponse{
+			Users: users,
+			Pagination: PaginationInfo{
+				Offset:     &offset,
+				Limit:      limit,
+				Total:      total,
+				HasMore:    page.HasNext,
+				NextCursor: encodeCursor(page.NextCursor()),
+				PrevCursor: encodeCursor(page.PrevCursor()),
+			},
+		}
+	}
+	if resp.Users == nil {
+		resp.Users = []leaderboard.RankedUser{}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -111,49 +278,42 @@ func (h *Handler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
 	username := r.PathValue("username")
 	if username == "" {
-		http.Error(w, "username is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "username is required"})
 		return
 	}
 
-	ranked, err := h.lb.GetUserRank(username)
+	ranked, err := lb.GetUserRank(username)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":    err.Error(),
-			"username": username,
-		})
+		writeUserError(w, err, username)
 		return
 	}
 
-	total := h.lb.Count()
-	percentile := 0.0
-	if total > 0 {
-		percentile = 100.0 * float64(total-ranked.Rank+1) / float64(total)
-	}
-
-	resp := UserResponse{
-		Username:   ranked.Username,
-		Rating:     ranked.Rating,
-		Rank:       ranked.Rank,
-		Percentile: percentile,
-	}
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(newUserResponse(lb, ranked))
 }
 
 // Search handles fuzzy user search
 func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
 	query := r.URL.Query().Get("q")
 	if len(query) < 2 {
-		http.Error(w, "query must be at least 2 characters", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "query must be at least 2 characters"})
 		return
 	}
 
 	limit := 100 // Increased limit for better UX
-	results := h.lb.SearchUsers(query, limit)
+	results := lb.SearchUsers(query, limit)
 
 	// Convert to response format
 	// For search, we can reuse UserResponse or just return the RankedUser list
@@ -162,8 +322,61 @@ func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(results)
 }
 
+// GetCutoff returns the rating held at ?rank=N or at ?percentile=P
+func (h *Handler) GetCutoff(w http.ResponseWriter, r *http.Request) {
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	total := lb.Count()
+
+	var rank int
+	switch {
+	case q.Get("rank") != "":
+		n, err := strconv.Atoi(q.Get("rank"))
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, ErrorResponse{Error: "rank must be a positive integer"})
+			return
+		}
+		rank = n
+	case q.Get("percentile") != "":
+		p, err := strconv.ParseFloat(q.Get("percentile"), 64)
+		if err != nil || p <= 0 || p > 100 {
+			writeError(w, http.StatusBadRequest, ErrorResponse{Error: "percentile must be in (0, 100]"})
+			return
+		}
+		rank = leaderboard.RankForPercentile(total, p)
+	default:
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "rank or percentile is required"})
+		return
+	}
+
+	rating, err := lb.RatingAtRank(rank)
+	if err != nil {
+		writeError(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	resp := CutoffResponse{
+		Rank:       rank,
+		Percentile: 100.0 * float64(total-rank+1) / float64(total),
+		Rating:     rating,
+		Total:      total,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
 func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
-	stats := h.lb.GetStats()
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	stats := lb.GetStats()
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(stats)
 }
@@ -171,7 +384,7 @@ func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) StartSimulation(w http.ResponseWriter, r *http.Request) {
 	var req SimRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 		return
 	}
 
@@ -189,6 +402,7 @@ func (h *Handler) StartSimulation(w http.ResponseWriter, r *http.Request) {
 		UpdatesPerSecond: req.UpdatesPerSecond,
 		Duration:         time.Duration(req.DurationSeconds) * time.Second,
 		RatingChangeMax:  req.RatingChangeMax,
+		Seed:             req.Seed,
 	}
 
 	msg := h.sim.Start(cfg)
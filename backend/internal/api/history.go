@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goleaderboard/internal/leaderboard"
+)
+
+// GetUserHistory returns a user's rating time series from ?from= up to
+// ?to=, which includes the whole day when it is a plain date
+func (h *Handler) GetUserHistory(w http.ResponseWriter, r *http.Request) {
+	hs, ok := storeAs[leaderboard.HistoryStore](h, w, r, "rating history")
+	if !ok {
+		return
+	}
+
+	from, err := parseTimeParam(r.URL.Query().Get("from"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "from: " + err.Error()})
+		return
+	}
+	to, err := parseEndParam(r.URL.Query().Get("to"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "to: " + err.Error()})
+		return
+	}
+
+	username := r.PathValue("username")
+	history, err := hs.RatingHistory(username, from, to)
+	if err != nil {
+		writeUserError(w, err, username)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// parseTimeParam accepts RFC 3339, a plain date or unix seconds.
+// An empty value yields the zero time.
+func parseTimeParam(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, nil
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not RFC 3339, YYYY-MM-DD or unix seconds", v)
+}
+
+// parseEndParam parses the exclusive end of a window like parseTimeParam,
+// except that a plain date covers that whole day and so yields the
+// following midnight
+func parseEndParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t.AddDate(0, 0, 1), nil
+	}
+	return parseTimeParam(v)
+}
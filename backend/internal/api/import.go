@@ -0,0 +1,173 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"goleaderboard/internal/leaderboard"
+)
+
+// maxImportMemory is how much of a multipart upload is held in memory
+// before spilling to disk
+const maxImportMemory = 32 << 20
+
+// ImportResponse reports an import like BulkResponse does
+type ImportResponse struct {
+	*leaderboard.ImportResult
+	Summary map[string]int `json:"summary"`
+}
+
+// decodeCSVRows reads CSV with a header naming username and rating
+// columns in any order; other columns, such as an export's rank, are
+// ignored
+func decodeCSVRows(body io.Reader) ([]leaderboard.BulkRow, error) {
+	cr := csv.NewReader(body)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	userCol, ratingCol := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "username":
+			userCol = i
+		case "rating":
+			ratingCol = i
+		}
+	}
+	if userCol < 0 || ratingCol < 0 {
+		return nil, errors.New("csv header must name username and rating columns")
+	}
+
+	var rows []leaderboard.BulkRow
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if len(rows) == maxBulkRows {
+			return nil, fmt.Errorf("at most %d rows per request", maxBulkRows)
+		}
+
+		line, _ := cr.FieldPos(0)
+		if userCol >= len(record) || ratingCol >= len(record) {
+			return nil, fmt.Errorf("line %d: missing username or rating", line)
+		}
+		row := leaderboard.BulkRow{Username: record[userCol]}
+		if field := strings.TrimSpace(record[ratingCol]); field != "" {
+			if rating, err := strconv.ParseInt(field, 10, 64); err != nil {
+				row.RatingError = fmt.Sprintf("line %d: rating %q is not an integer", line, field)
+			} else {
+				row.Rating = &rating
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// importBody returns the upload, either the raw body or the multipart
+// "file" field, and the format named by ?format= or else inferred from
+// the content type or file name
+func importBody(r *http.Request) (io.ReadCloser, string, error) {
+	format := r.URL.Query().Get("format")
+	body, contentType := r.Body, r.Header.Get("Content-Type")
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(maxImportMemory); err != nil {
+			return nil, "", err
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, "", err
+		}
+		body, contentType = file, header.Header.Get("Content-Type")
+		if format == "" && path.Ext(header.Filename) == ".csv" {
+			format = "csv"
+		}
+		mediaType, _, _ = mime.ParseMediaType(contentType)
+	}
+
+	if format == "" {
+		format = "ndjson"
+		if mediaType == "text/csv" {
+			format = "csv"
+		}
+	}
+	return body, format, nil
+}
+
+// Import loads users from a CSV or NDJSON upload. ?conflict= picks
+// skip, overwrite or fail (default) for users that already exist, and
+// ?dry_run=true validates the upload without writing.
+func (h *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	conflict := r.URL.Query().Get("conflict")
+	if conflict == "" {
+		conflict = leaderboard.ConflictFail
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	body, format, err := importBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	defer body.Close()
+
+	var rows []leaderboard.BulkRow
+	switch format {
+	case "csv":
+		rows, err = decodeCSVRows(body)
+	case "ndjson":
+		rows, err = decodeBulkRows(body)
+	default:
+		err = errors.New("format must be csv or ndjson")
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	result, err := leaderboard.Import(lb, rows, conflict, dryRun)
+	status := http.StatusOK
+	switch {
+	case errors.Is(err, leaderboard.ErrImportConflict):
+		status = http.StatusConflict
+	case errors.Is(err, leaderboard.ErrInvalidBulk):
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	resp := ImportResponse{ImportResult: result, Summary: make(map[string]int)}
+	if resp.Results == nil {
+		resp.Results = []leaderboard.BulkResult{}
+	}
+	for _, res := range resp.Results {
+		resp.Summary[res.Status]++
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
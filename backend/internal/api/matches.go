@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"goleaderboard/internal/leaderboard"
+)
+
+// RecordMatch applies a match result and returns the rating deltas
+func (h *Handler) RecordMatch(w http.ResponseWriter, r *http.Request) {
+	ms, ok := storeAs[leaderboard.MatchStore](h, w, r, "matches")
+	if !ok {
+		return
+	}
+
+	var m leaderboard.Match
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	result, err := ms.RecordMatch(m)
+	switch {
+	case errors.Is(err, leaderboard.ErrInvalidMatch):
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	case errors.Is(err, leaderboard.ErrUserNotFound):
+		writeError(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	case errors.Is(err, leaderboard.ErrUnsupported):
+		writeError(w, http.StatusNotImplemented, ErrorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// CloseRatingPeriod rates the matches queued on a batched (Glicko-2) board
+func (h *Handler) CloseRatingPeriod(w http.ResponseWriter, r *http.Request) {
+	ps, ok := storeAs[leaderboard.RatingPeriodStore](h, w, r, "rating periods")
+	if !ok {
+		return
+	}
+
+	period, err := ps.CloseRatingPeriod()
+	switch {
+	case errors.Is(err, leaderboard.ErrNotBatched):
+		writeError(w, http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(period)
+}
@@ -8,15 +8,68 @@ import (
 func NewHandlerWithMiddleware(h *Handler) http.Handler {
 	mux := http.NewServeMux()
 
+	// Board-scoped endpoints are served twice: under /api for the default
+	// board and under /api/boards/{board} for named boards
+	board := func(method, path string, fn http.HandlerFunc) {
+		mux.HandleFunc(method+" /api"+path, fn)
+		mux.HandleFunc(method+" /api/boards/{board}"+path, fn)
+	}
+
 	// API Endpoints
 	// Using Go 1.22+ method matching
-	mux.HandleFunc("POST /api/seed", h.Seed)
-	mux.HandleFunc("GET /api/leaderboard", h.GetLeaderboard)
-	mux.HandleFunc("GET /api/user/{username}", h.GetUser)
-	mux.HandleFunc("GET /api/search", h.Search) // Added search endpoint
-	mux.HandleFunc("GET /api/stats", h.GetStats)
+	board("POST", "/seed", h.Seed)
+	board("POST", "/users", h.CreateUser)
+	board("POST", "/users/bulk", h.BulkUsers)
+	board("GET", "/leaderboard", h.GetLeaderboard)
+	board("GET", "/user/{username}", h.GetUser)
+	board("DELETE", "/user/{username}", h.DeleteUser)
+	board("PUT", "/user/{username}/rating", h.SetRating)
+	board("PATCH", "/user/{username}/rating", h.AdjustRating)
+	board("POST", "/user/{username}/increment", h.AdjustRating)
+	board("POST", "/user/{username}/scores", h.SubmitScore)
+	board("GET", "/user/{username}/history", h.GetUserHistory)
+	board("GET", "/user/{username}/around", h.GetAround)
+	board("GET", "/search", h.Search) // Added search endpoint
+	board("GET", "/cutoff", h.GetCutoff)
+	board("GET", "/stats", h.GetStats)
+	board("GET", "/export", h.Export)
+	board("POST", "/import", h.Import)
+	board("POST", "/matches", h.RecordMatch)
+	board("POST", "/rating-period", h.CloseRatingPeriod)
 	mux.HandleFunc("POST /api/simulate", h.StartSimulation)
 
+	// Server-Sent Events
+	board("GET", "/stream/leaderboard", h.StreamLeaderboard)
+	board("GET", "/stream/user/{username}", h.StreamUser)
+	board("GET", "/ws", h.ServeWebSocket)
+
+	// Seasons
+	board("POST", "/seasons", h.EndSeason)
+	board("GET", "/seasons", h.ListSeasons)
+	board("GET", "/seasons/{season}/leaderboard", h.GetSeasonLeaderboard)
+	board("GET", "/user/{username}/seasons", h.GetUserSeasons)
+
+	// Friends; the graph is shared by every board
+	mux.HandleFunc("GET /api/user/{username}/friends", h.ListFriends)
+	mux.HandleFunc("PUT /api/user/{username}/friends/{friend}", h.AddFriend)
+	mux.HandleFunc("DELETE /api/user/{username}/friends/{friend}", h.RemoveFriend)
+	board("GET", "/user/{username}/friends/leaderboard", h.GetFriendsLeaderboard)
+
+	// Groups; membership is shared by every board
+	mux.HandleFunc("GET /api/groups", h.ListGroups)
+	mux.HandleFunc("POST /api/groups", h.CreateGroup)
+	mux.HandleFunc("DELETE /api/group/{group}", h.DeleteGroup)
+	mux.HandleFunc("PUT /api/group/{group}/members/{username}", h.JoinGroup)
+	mux.HandleFunc("DELETE /api/group/{group}/members/{username}", h.LeaveGroup)
+	board("GET", "/groups/leaderboard", h.GetGroupLeaderboard)
+	board("GET", "/group/{group}", h.GetGroup)
+
+	// Board management
+	mux.HandleFunc("GET /api/boards", h.ListBoards)
+	mux.HandleFunc("POST /api/boards", h.CreateBoard)
+	mux.HandleFunc("GET /api/boards/{board}", h.GetBoard)
+	mux.HandleFunc("DELETE /api/boards/{board}", h.DeleteBoard)
+
 	// Wrap with Middleware: Logger(CORS(Mux))
 	// CORS should be outer to handle OPTIONS requests before Logger or logic
 	return Logger(CORSMiddleware(mux))
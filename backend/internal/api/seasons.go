@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"goleaderboard/internal/leaderboard"
+)
+
+// EndSeasonRequest represents the end season body
+type EndSeasonRequest struct {
+	Name  string                  `json:"name"`
+	Reset leaderboard.SeasonReset `json:"reset"`
+}
+
+// EndSeason closes the current season and archives its standings
+func (h *Handler) EndSeason(w http.ResponseWriter, r *http.Request) {
+	ss, ok := storeAs[leaderboard.SeasonStore](h, w, r, "seasons")
+	if !ok {
+		return
+	}
+
+	var req EndSeasonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	season, err := ss.EndSeason(req.Name, req.Reset)
+	switch {
+	case errors.Is(err, leaderboard.ErrInvalidReset):
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	case errors.Is(err, leaderboard.ErrSeasonExists):
+		writeError(w, http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(season)
+}
+
+func (h *Handler) ListSeasons(w http.ResponseWriter, r *http.Request) {
+	ss, ok := storeAs[leaderboard.SeasonStore](h, w, r, "seasons")
+	if !ok {
+		return
+	}
+
+	seasons, err := ss.ListSeasons()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(seasons)
+}
+
+// GetSeasonLeaderboard pages through a past season's final standings
+func (h *Handler) GetSeasonLeaderboard(w http.ResponseWriter, r *http.Request) {
+	ss, ok := storeAs[leaderboard.SeasonStore](h, w, r, "seasons")
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("season"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "season must be a numeric id"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	season, users, err := ss.SeasonStandings(id, limit, offset)
+	if errors.Is(err, leaderboard.ErrSeasonNotFound) {
+		writeError(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"season": season,
+		"users":  users,
+		"pagination": PaginationInfo{
+			Offset:  &offset,
+			Limit:   limit,
+			Total:   season.Players,
+			HasMore: offset+len(users) < season.Players,
+		},
+	})
+}
+
+// GetUserSeasons lists a user's finishes in past seasons
+func (h *Handler) GetUserSeasons(w http.ResponseWriter, r *http.Request) {
+	ss, ok := storeAs[leaderboard.SeasonStore](h, w, r, "seasons")
+	if !ok {
+		return
+	}
+
+	username := r.PathValue("username")
+	finishes, err := ss.UserSeasons(username)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"username": username,
+		"seasons":  finishes,
+	})
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goleaderboard/internal/leaderboard"
+)
+
+const (
+	// streamInterval is the most often a stream re-reads the board.
+	// Changes arriving in between are coalesced into one update.
+	streamInterval = 250 * time.Millisecond
+	// streamHeartbeat keeps idle connections from being closed by proxies
+	streamHeartbeat = 15 * time.Second
+	// streamBuffer is how many changes a stream may fall behind by before
+	// changes are dropped; a dropped change still triggers a re-read
+	streamBuffer = 256
+)
+
+// sseStream writes Server-Sent Events to one client
+type sseStream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// startSSE lifts the server's write timeout, which would otherwise cut
+// the stream off, and sends the event stream headers. Errors come before
+// any header is written; callers flush the headers once it returns.
+func startSSE(w http.ResponseWriter) (*sseStream, error) {
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return nil, err
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // disable nginx buffering
+	w.WriteHeader(http.StatusOK)
+
+	return &sseStream{w: w, rc: rc}, nil
+}
+
+func (s *sseStream) send(event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func (s *sseStream) heartbeat() error {
+	if _, err := fmt.Fprint(s.w, ": ping\n\n"); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// watch runs refresh once up front and then again after changes to the
+// board, at most once per streamInterval, until the client goes away or
+// a write fails
+func watch(r *http.Request, s *sseStream, lb leaderboard.Store, refresh func() error) {
+	sub := lb.Changes().Subscribe(streamBuffer)
+	defer sub.Close()
+
+	if err := refresh(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	var flush <-chan time.Time
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if err := s.heartbeat(); err != nil {
+				return
+			}
+		case <-sub.C():
+			if flush == nil {
+				flush = time.After(streamInterval)
+			}
+		case <-flush:
+			flush = nil
+			// Everything queued so far is covered by this refresh
+			for drained := false; !drained; {
+				select {
+				case <-sub.C():
+				default:
+					drained = true
+				}
+			}
+			sub.Lagged()
+			if err := refresh(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// StreamLeaderboard pushes the top ?limit=N users as a "snapshot" event,
+// then a "diff" event listing who entered, left, moved or changed rating
+// whenever the top N changes
+func (h *Handler) StreamLeaderboard(w http.ResponseWriter, r *http.Request) {
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 10
+	}
+
+	s, err := startSSE(w)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	// The headers are out, so a failure can only be logged
+	if err := s.rc.Flush(); err != nil {
+		log.Printf("Leaderboard stream of board %s failed to start: %v", lb.Config().Name, err)
+		return
+	}
+
+	var prev []leaderboard.RankedUser
+	started := false
+	watch(r, s, lb, func() error {
+		cur := lb.GetTopN(limit, 0)
+		if !started {
+			started, prev = true, cur
+			return s.send("snapshot", cur)
+		}
+		diff := leaderboard.DiffTopN(prev, cur)
+		prev = cur
+		if diff.Empty() {
+			return nil
+		}
+		return s.send("diff", diff)
+	})
+}
+
+// StreamUser pushes a "rank" event with the user's rating and rank, then
+// another whenever either changes. A "removed" event is sent if the user
+// is deleted; the stream stays open in case they are re-added.
+func (h *Handler) StreamUser(w http.ResponseWriter, r *http.Request) {
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	username := r.PathValue("username")
+	if _, err := lb.GetUserRank(username); err != nil {
+		writeUserError(w, err, username)
+		return
+	}
+
+	s, err := startSSE(w)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := s.rc.Flush(); err != nil {
+		log.Printf("Stream of user %s on board %s failed to start: %v", username, lb.Config().Name, err)
+		return
+	}
+
+	var prev *leaderboard.RankChange
+	watch(r, s, lb, func() error {
+		ranked, err := lb.GetUserRank(username)
+		if errors.Is(err, leaderboard.ErrUserNotFound) {
+			if prev == nil {
+				return nil
+			}
+			prev = nil
+			return s.send("removed", ErrorResponse{Error: err.Error(), Username: username})
+		} else if err != nil {
+			return err
+		}
+
+		cur := &leaderboard.RankChange{Username: username, Rating: ranked.Rating, Rank: ranked.Rank}
+		if prev != nil {
+			if prev.Rating == cur.Rating && prev.Rank == cur.Rank {
+				return nil
+			}
+			cur.OldRating, cur.OldRank = &prev.Rating, &prev.Rank
+		}
+		prev = cur
+		return s.send("rank", cur)
+	})
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"goleaderboard/internal/leaderboard"
+)
+
+// maxAroundRadius caps the neighborhood size
+const maxAroundRadius = 50
+
+// CreateUserRequest represents the create user body
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Rating   *int64 `json:"rating"`
+}
+
+// SetRatingRequest represents the PUT rating body
+type SetRatingRequest struct {
+	Rating *int64 `json:"rating"`
+}
+
+// AdjustRatingRequest represents the PATCH rating and increment body:
+// a relative change, clamped to the board bounds
+type AdjustRatingRequest struct {
+	Delta int64 `json:"delta"`
+}
+
+// SubmitScoreRequest represents the score submission body. Policy
+// defaults to "best".
+type SubmitScoreRequest struct {
+	Score  *int64 `json:"score"`
+	Policy string `json:"policy"`
+}
+
+// SubmitScoreResponse is the user's entry after a submission
+type SubmitScoreResponse struct {
+	UserResponse
+	Previous *int64 `json:"previous"`
+	Changed  bool   `json:"changed"`
+}
+
+// writeUserError maps store errors for a user to status codes
+func writeUserError(w http.ResponseWriter, err error, username string) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, leaderboard.ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, leaderboard.ErrUserExists):
+		status = http.StatusConflict
+	case errors.Is(err, leaderboard.ErrInvalidRating), errors.Is(err, leaderboard.ErrInvalidPolicy):
+		status = http.StatusBadRequest
+	}
+	writeError(w, status, ErrorResponse{Error: err.Error(), Username: username})
+}
+
+// writeUser answers with the user's current rating and rank
+func writeUser(w http.ResponseWriter, lb leaderboard.Store, username string, status int) {
+	ranked, err := lb.GetUserRank(username)
+	if err != nil {
+		writeUserError(w, err, username)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(newUserResponse(lb, ranked))
+}
+
+func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if req.Username == "" || len(req.Username) > leaderboard.MaxUsernameLength {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "username must be 1 to 255 bytes", Username: req.Username})
+		return
+	}
+	if req.Rating == nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "rating is required", Username: req.Username})
+		return
+	}
+
+	if err := lb.AddUser(req.Username, *req.Rating); err != nil {
+		writeUserError(w, err, req.Username)
+		return
+	}
+	writeUser(w, lb, req.Username, http.StatusCreated)
+}
+
+// SetRating replaces a user's rating
+func (h *Handler) SetRating(w http.ResponseWriter, r *http.Request) {
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	username := r.PathValue("username")
+	var req SetRatingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error(), Username: username})
+		return
+	}
+	if req.Rating == nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "rating is required", Username: username})
+		return
+	}
+
+	if err := lb.UpdateRating(username, *req.Rating); err != nil {
+		writeUserError(w, err, username)
+		return
+	}
+	writeUser(w, lb, username, http.StatusOK)
+}
+
+// AdjustRating atomically moves a user's rating by a relative delta.
+// It serves both PATCH /rating and POST /increment.
+func (h *Handler) AdjustRating(w http.ResponseWriter, r *http.Request) {
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	username := r.PathValue("username")
+	var req AdjustRatingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error(), Username: username})
+		return
+	}
+
+	ranked, err := lb.IncrementRating(username, req.Delta, leaderboard.SourceAPI)
+	if err != nil {
+		writeUserError(w, err, username)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newUserResponse(lb, ranked))
+}
+
+// SubmitScore records a score under a submit policy, creating the user's
+// entry on their first submission
+func (h *Handler) SubmitScore(w http.ResponseWriter, r *http.Request) {
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	username := r.PathValue("username")
+	if len(username) > leaderboard.MaxUsernameLength {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "username must be 1 to 255 bytes", Username: username})
+		return
+	}
+	var req SubmitScoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error(), Username: username})
+		return
+	}
+	if req.Score == nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "score is required", Username: username})
+		return
+	}
+	if req.Policy == "" {
+		req.Policy = leaderboard.SubmitBest
+	}
+
+	result, err := lb.Submit(username, *req.Score, req.Policy, leaderboard.SourceAPI)
+	if err != nil {
+		writeUserError(w, err, username)
+		return
+	}
+
+	status := http.StatusOK
+	if result.Previous == nil {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(SubmitScoreResponse{
+		UserResponse: newUserResponse(lb, &result.RankedUser),
+		Previous:     result.Previous,
+		Changed:      result.Changed,
+	})
+}
+
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	username := r.PathValue("username")
+	if err := lb.DeleteUser(username); err != nil {
+		writeUserError(w, err, username)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AroundResponse is the user's neighborhood in leaderboard order
+type AroundResponse struct {
+	Username string                   `json:"username"`
+	Radius   int                      `json:"radius"`
+	Users    []leaderboard.RankedUser `json:"users"`
+}
+
+// GetAround returns the ?radius=N users above and below a user
+func (h *Handler) GetAround(w http.ResponseWriter, r *http.Request) {
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	username := r.PathValue("username")
+	radius, _ := strconv.Atoi(r.URL.Query().Get("radius"))
+	if radius <= 0 {
+		radius = 10
+	}
+	if radius > maxAroundRadius {
+		radius = maxAroundRadius
+	}
+
+	users, err := lb.GetAround(username, radius)
+	if err != nil {
+		writeUserError(w, err, username)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AroundResponse{Username: username, Radius: radius, Users: users})
+}
@@ -0,0 +1,337 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"goleaderboard/internal/leaderboard"
+)
+
+// WebSocket protocol
+//
+// The client sends WSRequests:
+//
+//	{"op": "subscribe", "id": "top", "kind": "top", "limit": 100}
+//	{"op": "subscribe", "id": "me", "kind": "around", "username": "alice", "radius": 10}
+//	{"op": "subscribe", "id": "friends", "kind": "users", "usernames": ["bob", "carol"]}
+//	{"op": "unsubscribe", "id": "top"}
+//
+// and receives WSMessages: a "snapshot" of the window once subscribed,
+// then a "diff" whenever it changes. Subscribing again with the same id
+// replaces the subscription. Errors are reported per request and do not
+// close the connection.
+
+const (
+	wsKindTop    = "top"
+	wsKindAround = "around"
+	wsKindUsers  = "users"
+
+	wsMaxSubscriptions = 16
+	wsMaxUsernames     = 100
+	wsMaxMessage       = 64 << 10
+
+	// wsSendBuffer is how many messages a client may fall behind by before
+	// it is dropped. Feed publishers never wait on clients either way.
+	wsSendBuffer = 64
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Same policy as CORSMiddleware: any origin may connect
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// WSRequest is a message from a WebSocket client
+type WSRequest struct {
+	Op        string   `json:"op"`   // "subscribe" or "unsubscribe"
+	ID        string   `json:"id"`   // client-chosen subscription id
+	Kind      string   `json:"kind"` // "top", "around" or "users"
+	Limit     int      `json:"limit,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	Radius    int      `json:"radius,omitempty"`
+	Usernames []string `json:"usernames,omitempty"`
+}
+
+// WSMessage is a message to a WebSocket client. Users is omitted from
+// empty snapshots.
+type WSMessage struct {
+	Type  string                   `json:"type"` // "snapshot", "diff", "unsubscribed" or "error"
+	ID    string                   `json:"id,omitempty"`
+	Users []leaderboard.RankedUser `json:"users,omitempty"`
+	Diff  *leaderboard.TopNDiff    `json:"diff,omitempty"`
+	Error string                   `json:"error,omitempty"`
+}
+
+// wsSubscription is one window a client watches
+type wsSubscription struct {
+	req  WSRequest
+	prev []leaderboard.RankedUser
+}
+
+// validate checks the request and fills in defaults
+func (req *WSRequest) validate() error {
+	if req.ID == "" {
+		return errors.New("id is required")
+	}
+	switch req.Kind {
+	case wsKindTop:
+		if req.Limit <= 0 || req.Limit > 100 {
+			req.Limit = 10
+		}
+	case wsKindAround:
+		if req.Username == "" {
+			return errors.New("username is required")
+		}
+		if req.Radius <= 0 {
+			req.Radius = 10
+		}
+		if req.Radius > maxAroundRadius {
+			return fmt.Errorf("radius may be at most %d", maxAroundRadius)
+		}
+	case wsKindUsers:
+		if len(req.Usernames) == 0 || len(req.Usernames) > wsMaxUsernames {
+			return fmt.Errorf("usernames must list 1 to %d users", wsMaxUsernames)
+		}
+	default:
+		return fmt.Errorf("kind must be %q, %q or %q", wsKindTop, wsKindAround, wsKindUsers)
+	}
+	return nil
+}
+
+// read returns the users currently in the window, in rank order
+func (s *wsSubscription) read(lb leaderboard.Store) ([]leaderboard.RankedUser, error) {
+	switch s.req.Kind {
+	case wsKindTop:
+		return lb.GetTopN(s.req.Limit, 0), nil
+	case wsKindAround:
+		users, err := lb.GetAround(s.req.Username, s.req.Radius)
+		if errors.Is(err, leaderboard.ErrUserNotFound) {
+			return nil, nil
+		}
+		return users, err
+	default:
+		users := make([]leaderboard.RankedUser, 0, len(s.req.Usernames))
+		for _, name := range s.req.Usernames {
+			u, err := lb.GetUserRank(name)
+			if errors.Is(err, leaderboard.ErrUserNotFound) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			users = append(users, *u)
+		}
+		sort.Slice(users, func(i, j int) bool {
+			if users[i].Rank != users[j].Rank {
+				return users[i].Rank < users[j].Rank
+			}
+			return users[i].Username < users[j].Username
+		})
+		return users, nil
+	}
+}
+
+// wsClient is one WebSocket connection
+type wsClient struct {
+	conn *websocket.Conn
+	lb   leaderboard.Store
+	send chan WSMessage
+
+	mu   sync.Mutex // guards subs
+	subs map[string]*wsSubscription
+
+	done      chan struct{}
+	closeOnce sync.Once
+	slow      atomic.Bool // dropped for falling behind
+}
+
+// ServeWebSocket upgrades the connection and serves subscriptions on the board
+func (h *Handler) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
+	lb, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade has already answered the request
+	}
+
+	c := &wsClient{
+		conn: conn,
+		lb:   lb,
+		send: make(chan WSMessage, wsSendBuffer),
+		subs: make(map[string]*wsSubscription),
+		done: make(chan struct{}),
+	}
+	go c.writeLoop()
+	go c.refreshLoop()
+	c.readLoop()
+}
+
+// close stops the client; writeLoop then closes the connection, which
+// unblocks readLoop
+func (c *wsClient) close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+// enqueue queues msg for the client, dropping the client if it has
+// fallen too far behind
+func (c *wsClient) enqueue(msg WSMessage) {
+	select {
+	case c.send <- msg:
+	case <-c.done:
+	default:
+		c.slow.Store(true)
+		c.close()
+	}
+}
+
+func (c *wsClient) readLoop() {
+	defer c.close()
+
+	c.conn.SetReadLimit(wsMaxMessage)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req WSRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			c.enqueue(WSMessage{Type: "error", Error: err.Error()})
+			continue
+		}
+
+		switch req.Op {
+		case "subscribe":
+			c.subscribe(req)
+		case "unsubscribe":
+			c.mu.Lock()
+			delete(c.subs, req.ID)
+			c.mu.Unlock()
+			c.enqueue(WSMessage{Type: "unsubscribed", ID: req.ID})
+		default:
+			c.enqueue(WSMessage{Type: "error", ID: req.ID, Error: `op must be "subscribe" or "unsubscribe"`})
+		}
+	}
+}
+
+func (c *wsClient) subscribe(req WSRequest) {
+	if err := req.validate(); err != nil {
+		c.enqueue(WSMessage{Type: "error", ID: req.ID, Error: err.Error()})
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.subs[req.ID]; !ok && len(c.subs) >= wsMaxSubscriptions {
+		c.enqueue(WSMessage{Type: "error", ID: req.ID, Error: fmt.Sprintf("at most %d subscriptions per connection", wsMaxSubscriptions)})
+		return
+	}
+
+	sub := &wsSubscription{req: req}
+	users, err := sub.read(c.lb)
+	if err != nil {
+		c.enqueue(WSMessage{Type: "error", ID: req.ID, Error: err.Error()})
+		return
+	}
+	sub.prev = users
+	c.subs[req.ID] = sub
+	c.enqueue(WSMessage{Type: "snapshot", ID: req.ID, Users: users})
+}
+
+// refreshLoop re-reads every subscription after board changes, coalescing
+// bursts like the SSE streams do, and sends the windows that changed
+func (c *wsClient) refreshLoop() {
+	feed := c.lb.Changes().Subscribe(streamBuffer)
+	defer feed.Close()
+
+	var flush <-chan time.Time
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-feed.C():
+			if flush == nil {
+				flush = time.After(streamInterval)
+			}
+		case <-flush:
+			flush = nil
+			for drained := false; !drained; {
+				select {
+				case <-feed.C():
+				default:
+					drained = true
+				}
+			}
+			feed.Lagged()
+			c.refresh()
+		}
+	}
+}
+
+func (c *wsClient) refresh() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, sub := range c.subs {
+		users, err := sub.read(c.lb)
+		if err != nil {
+			c.enqueue(WSMessage{Type: "error", ID: id, Error: err.Error()})
+			continue
+		}
+		diff := leaderboard.DiffTopN(sub.prev, users)
+		sub.prev = users
+		if !diff.Empty() {
+			c.enqueue(WSMessage{Type: "diff", ID: id, Diff: &diff})
+		}
+	}
+}
+
+func (c *wsClient) writeLoop() {
+	ping := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ping.Stop()
+		c.close()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case <-c.done:
+			if c.slow.Load() {
+				// Tell the client why it was dropped, best effort
+				c.conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow"),
+					time.Now().Add(time.Second))
+			}
+			return
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
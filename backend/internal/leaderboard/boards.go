@@ -0,0 +1,210 @@
+package leaderboard
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultBoard is the board served by the un-prefixed /api routes
+const DefaultBoard = "global"
+
+// MaxScore bounds the magnitude of board bounds, so that the difference
+// between any two scores on a board fits in an int64
+const MaxScore = math.MaxInt64 / 2
+
+// MaxDecimals bounds a board's fixed-point scale
+const MaxDecimals = 9
+
+var (
+	ErrUnsupported   = errors.New("not supported")
+	ErrBoardNotFound = errors.New("board not found")
+	ErrBoardExists   = errors.New("board already exists")
+	ErrInvalidBoard  = errors.New("invalid board")
+)
+
+var boardNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// BoardConfig describes a named leaderboard (per game, region, season...)
+type BoardConfig struct {
+	Name      string `json:"name"`
+	MinRating int64  `json:"min_rating"`
+	MaxRating int64  `json:"max_rating"`
+	// RatingSystem rates match results: "elo" (default) or "glicko2"
+	RatingSystem string  `json:"rating_system"`
+	KFactor      float64 `json:"k_factor"` // Elo K-factor
+	// RankingMode ranks equal ratings: "competition" (default), "dense"
+	// or "ordinal"
+	RankingMode string `json:"ranking_mode"`
+	// TieBreak orders equal ratings: "username" (default), "achieved"
+	// or "created"
+	TieBreak string `json:"tie_break"`
+	// Order is "desc" (default) when higher ratings rank ahead, or "asc"
+	// for boards where lower is better, such as speedrun times
+	Order string `json:"order"`
+	// Decimals is how many trailing digits of a score are fractional, for
+	// scores such as times in seconds. Scores are still stored and sent as
+	// integers: with 3 decimals a time of 61.25s is the score 61250.
+	Decimals  int       `json:"decimals"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DefaultBoardConfig is the config of the built-in global board
+func DefaultBoardConfig() BoardConfig {
+	return BoardConfig{
+		Name:         DefaultBoard,
+		MinRating:    MinRating,
+		MaxRating:    MaxRating,
+		RatingSystem: RatingSystemElo,
+		KFactor:      DefaultKFactor,
+		RankingMode:  RankCompetition,
+		TieBreak:     TieBreakUsername,
+		Order:        OrderDescending,
+	}
+}
+
+// withDefaults fills in optional settings left unset
+func (c BoardConfig) withDefaults() BoardConfig {
+	if c.RatingSystem == "" {
+		c.RatingSystem = RatingSystemElo
+	}
+	if c.KFactor == 0 {
+		c.KFactor = DefaultKFactor
+	}
+	if c.RankingMode == "" {
+		c.RankingMode = RankCompetition
+	}
+	if c.TieBreak == "" {
+		c.TieBreak = TieBreakUsername
+	}
+	if c.Order == "" {
+		c.Order = OrderDescending
+	}
+	return c
+}
+
+// Validate checks the board name and rating bounds
+func (c BoardConfig) Validate() error {
+	if !boardNamePattern.MatchString(c.Name) {
+		return fmt.Errorf("%w: name must match %s", ErrInvalidBoard, boardNamePattern)
+	}
+	if c.MinRating >= c.MaxRating {
+		return fmt.Errorf("%w: min_rating must be below max_rating", ErrInvalidBoard)
+	}
+	if c.MinRating < -MaxScore || c.MaxRating > MaxScore {
+		return fmt.Errorf("%w: bounds must be within ±%d", ErrInvalidBoard, MaxScore)
+	}
+	if c.RatingSystem != RatingSystemElo && c.RatingSystem != RatingSystemGlicko2 {
+		return fmt.Errorf("%w: rating_system must be %q or %q", ErrInvalidBoard, RatingSystemElo, RatingSystemGlicko2)
+	}
+	if c.KFactor <= 0 {
+		return fmt.Errorf("%w: k_factor must be positive", ErrInvalidBoard)
+	}
+	switch c.RankingMode {
+	case RankCompetition, RankDense, RankOrdinal:
+	default:
+		return fmt.Errorf("%w: ranking_mode must be %q, %q or %q", ErrInvalidBoard, RankCompetition, RankDense, RankOrdinal)
+	}
+	switch c.TieBreak {
+	case TieBreakUsername, TieBreakAchieved, TieBreakCreated:
+	default:
+		return fmt.Errorf("%w: tie_break must be %q, %q or %q", ErrInvalidBoard, TieBreakUsername, TieBreakAchieved, TieBreakCreated)
+	}
+	if c.Order != OrderDescending && c.Order != OrderAscending {
+		return fmt.Errorf("%w: order must be %q or %q", ErrInvalidBoard, OrderDescending, OrderAscending)
+	}
+	if c.Decimals < 0 || c.Decimals > MaxDecimals {
+		return fmt.Errorf("%w: decimals must be between 0 and %d", ErrInvalidBoard, MaxDecimals)
+	}
+	return nil
+}
+
+// checkRating returns ErrInvalidRating if rating is outside the board bounds
+func (c BoardConfig) checkRating(rating int64) error {
+	if rating < c.MinRating || rating > c.MaxRating {
+		return fmt.Errorf("%w: must be between %d and %d", ErrInvalidRating, c.MinRating, c.MaxRating)
+	}
+	return nil
+}
+
+// Boards manages named leaderboards, each served by its own Store
+type Boards interface {
+	Board(name string) (Store, error)
+	CreateBoard(cfg BoardConfig) (BoardConfig, error)
+	DeleteBoard(name string) error
+	ListBoards() []BoardConfig
+}
+
+// MemoryBoards is an in-memory Boards registry of Leaderboards
+type MemoryBoards struct {
+	mu     sync.RWMutex
+	boards map[string]*Leaderboard
+}
+
+// NewMemoryBoards creates a registry holding just the default board
+func NewMemoryBoards() *MemoryBoards {
+	cfg := DefaultBoardConfig()
+	cfg.CreatedAt = time.Now()
+	return &MemoryBoards{
+		boards: map[string]*Leaderboard{DefaultBoard: NewLeaderboardFor(cfg)},
+	}
+}
+
+func (m *MemoryBoards) Board(name string) (Store, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	lb, ok := m.boards[name]
+	if !ok {
+		return nil, ErrBoardNotFound
+	}
+	return lb, nil
+}
+
+func (m *MemoryBoards) CreateBoard(cfg BoardConfig) (BoardConfig, error) {
+	cfg = cfg.withDefaults()
+	if err := cfg.Validate(); err != nil {
+		return BoardConfig{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.boards[cfg.Name]; ok {
+		return BoardConfig{}, ErrBoardExists
+	}
+	cfg.CreatedAt = time.Now()
+	m.boards[cfg.Name] = NewLeaderboardFor(cfg)
+	return cfg, nil
+}
+
+func (m *MemoryBoards) DeleteBoard(name string) error {
+	if name == DefaultBoard {
+		return fmt.Errorf("%w: the default board cannot be deleted", ErrInvalidBoard)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.boards[name]; !ok {
+		return ErrBoardNotFound
+	}
+	delete(m.boards, name)
+	return nil
+}
+
+func (m *MemoryBoards) ListBoards() []BoardConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	configs := make([]BoardConfig, 0, len(m.boards))
+	for _, lb := range m.boards {
+		configs = append(configs, lb.Config())
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Name < configs[j].Name })
+	return configs
+}
@@ -0,0 +1,189 @@
+package leaderboard
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidBulk = errors.New("invalid bulk write")
+
+// MaxUsernameLength matches the users.username column
+const MaxUsernameLength = 255
+
+// BulkBatchSize is how many rows a bulk write applies per transaction
+const BulkBatchSize = 500
+
+// Bulk write modes
+const (
+	BulkUpsert = "upsert" // create missing users, overwrite the rest
+	BulkInsert = "insert" // only create; existing users are duplicates
+	BulkUpdate = "update" // only overwrite; missing users are not found
+)
+
+// Bulk row outcomes
+const (
+	BulkCreated         = "created"
+	BulkUpdated         = "updated"
+	BulkUnchanged       = "unchanged"
+	BulkInvalidRating   = "invalid_rating"
+	BulkInvalidUsername = "invalid_username"
+	BulkDuplicate       = "duplicate" // already exists, or repeats an earlier row
+	BulkNotFound        = "not_found"
+	BulkFailed          = "error" // the row's batch failed as a whole
+)
+
+// BulkRow is one user of a bulk write. RatingError is set by decoders
+// when the row's rating could not be read, which fails just that row.
+type BulkRow struct {
+	Username    string `json:"username"`
+	Rating      *int64 `json:"rating"`
+	RatingError string `json:"-"`
+}
+
+// BulkResult is the outcome of the bulk row at Index
+type BulkResult struct {
+	Index    int    `json:"index"`
+	Username string `json:"username"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// checkBulkMode returns ErrInvalidBulk for an unknown mode
+func checkBulkMode(mode string) error {
+	switch mode {
+	case BulkUpsert, BulkInsert, BulkUpdate:
+		return nil
+	default:
+		return fmt.Errorf("%w: mode must be %q, %q or %q", ErrInvalidBulk, BulkUpsert, BulkInsert, BulkUpdate)
+	}
+}
+
+// checkBulkRows returns a result per row with the rows that cannot be
+// applied already settled. Rows left to apply have an empty Status.
+func (c BoardConfig) checkBulkRows(rows []BulkRow) []BulkResult {
+	results := make([]BulkResult, len(rows))
+	seen := make(map[string]bool, len(rows))
+	for i, row := range rows {
+		results[i] = BulkResult{Index: i, Username: row.Username}
+		switch {
+		case row.Username == "" || len(row.Username) > MaxUsernameLength:
+			results[i].Status = BulkInvalidUsername
+			results[i].Error = fmt.Sprintf("username must be 1 to %d bytes", MaxUsernameLength)
+		case row.RatingError != "":
+			results[i].Status = BulkInvalidRating
+			results[i].Error = row.RatingError
+		case row.Rating == nil:
+			results[i].Status = BulkInvalidRating
+			results[i].Error = "rating is required"
+		case seen[row.Username]:
+			results[i].Status = BulkDuplicate
+			results[i].Error = "username appears earlier in the request"
+		default:
+			if err := c.checkRating(*row.Rating); err != nil {
+				results[i].Status = BulkInvalidRating
+				results[i].Error = err.Error()
+			}
+		}
+		seen[row.Username] = true
+	}
+	return results
+}
+
+// bulkOutcome is the status of applying a valid row under mode, given
+// the user's current rating or nil if they have none
+func bulkOutcome(mode string, old *int64, rating int64) string {
+	switch {
+	case old != nil && mode == BulkInsert:
+		return BulkDuplicate
+	case old == nil && mode == BulkUpdate:
+		return BulkNotFound
+	case old == nil:
+		return BulkCreated
+	case *old == rating:
+		return BulkUnchanged
+	default:
+		return BulkUpdated
+	}
+}
+
+// BulkWrite applies rows under one lock acquisition and publishes a
+// single bulk change
+func (lb *Leaderboard) BulkWrite(rows []BulkRow, mode string) ([]BulkResult, error) {
+	if err := checkBulkMode(mode); err != nil {
+		return nil, err
+	}
+	results := lb.cfg.checkBulkRows(rows)
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	for i, row := range rows {
+		if results[i].Status != "" {
+			continue
+		}
+		var old *int64
+		if rating, ok := lb.users[row.Username]; ok {
+			old = &rating
+		}
+		results[i].Status = bulkOutcome(mode, old, *row.Rating)
+		switch results[i].Status {
+		case BulkCreated:
+			lb.insert(row.Username, *row.Rating)
+		case BulkUpdated:
+			lb.move(row.Username, *row.Rating)
+		}
+	}
+	lb.feed.publishBulk(SourceBulk)
+	return results, nil
+}
+
+// PlanBulk reports what BulkWrite would do with rows without writing
+func (lb *Leaderboard) PlanBulk(rows []BulkRow, mode string) ([]BulkResult, error) {
+	if err := checkBulkMode(mode); err != nil {
+		return nil, err
+	}
+	results := lb.cfg.checkBulkRows(rows)
+
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+
+	for i, row := range rows {
+		if results[i].Status != "" {
+			continue
+		}
+		var old *int64
+		if rating, ok := lb.users[row.Username]; ok {
+			old = &rating
+		}
+		results[i].Status = bulkOutcome(mode, old, *row.Rating)
+	}
+	return results, nil
+}
+
+// InsertAll creates every row under one lock acquisition, or none of
+// them if any row is invalid or its user exists
+func (lb *Leaderboard) InsertAll(rows []BulkRow) ([]BulkResult, error) {
+	results := lb.cfg.checkBulkRows(rows)
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	for i, row := range rows {
+		if results[i].Status != "" {
+			continue
+		}
+		var old *int64
+		if rating, ok := lb.users[row.Username]; ok {
+			old = &rating
+		}
+		results[i].Status = bulkOutcome(BulkInsert, old, *row.Rating)
+	}
+	if err := importConflict(results); err != nil {
+		return results, err
+	}
+	for _, row := range rows {
+		lb.insert(row.Username, *row.Rating)
+	}
+	lb.feed.publishBulk(SourceBulk)
+	return results, nil
+}
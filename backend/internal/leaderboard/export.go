@@ -0,0 +1,22 @@
+package leaderboard
+
+// exportFetchSize is how many rows an export reads from the database
+// per round trip
+const exportFetchSize = 1000
+
+// Export calls fn for every user in leaderboard order, stopping at the
+// first error fn returns. The board is copied under the lock, so fn may
+// be slow without blocking writers.
+func (lb *Leaderboard) Export(fn func(RankedUser) error) error {
+	lb.mu.RLock()
+	sorted := lb.sorted()
+	lb.mu.RUnlock()
+
+	walk := rankWalk{mode: lb.cfg.RankingMode}
+	for _, u := range sorted {
+		if err := fn(RankedUser{User: u, Rank: walk.next(u.Rating)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package leaderboard
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Change is one write published on a board's Feed. Bulk writes that touch
+// many users at once (seeding, season resets, rating periods) are published
+// as a single Change with no Username; subscribers should re-read whatever
+// they are watching.
+type Change struct {
+	Board     string    `json:"board"`
+	Username  string    `json:"username,omitempty"`
+	OldRating *int64    `json:"old_rating"` // nil when the user was created
+	NewRating *int64    `json:"new_rating"` // nil when the user was deleted
+	Source    Source    `json:"source"`
+	At        time.Time `json:"at"`
+}
+
+// Bulk reports whether the change may have touched any user
+func (c Change) Bulk() bool {
+	return c.Username == ""
+}
+
+// Feed fans changes out to subscribers. Publishing never blocks: a
+// subscriber whose buffer is full misses the change and is marked lagged,
+// so watchers should treat changes as hints to re-read rather than as a
+// complete log.
+type Feed struct {
+	board string
+	mu    sync.RWMutex
+	subs  map[*Subscription]struct{}
+}
+
+// Subscription receives changes from a Feed until closed
+type Subscription struct {
+	feed   *Feed
+	ch     chan Change
+	lagged atomic.Bool
+}
+
+func NewFeed(board string) *Feed {
+	return &Feed{
+		board: board,
+		subs:  make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe registers a subscriber with room for buffer pending changes
+func (f *Feed) Subscribe(buffer int) *Subscription {
+	s := &Subscription{feed: f, ch: make(chan Change, buffer)}
+	f.mu.Lock()
+	f.subs[s] = struct{}{}
+	f.mu.Unlock()
+	return s
+}
+
+// Publish delivers c to every subscriber that has room for it
+func (f *Feed) Publish(c Change) {
+	c.Board = f.board
+	if c.At.IsZero() {
+		c.At = time.Now()
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for s := range f.subs {
+		select {
+		case s.ch <- c:
+		default:
+			s.lagged.Store(true)
+		}
+	}
+}
+
+// publishUser publishes a single user's rating change
+func (f *Feed) publishUser(username string, oldRating, newRating *int64, source Source) {
+	f.Publish(Change{Username: username, OldRating: oldRating, NewRating: newRating, Source: source})
+}
+
+// publishBulk publishes a change that may have touched every user
+func (f *Feed) publishBulk(source Source) {
+	f.Publish(Change{Source: source})
+}
+
+// C returns the channel changes are delivered on
+func (s *Subscription) C() <-chan Change {
+	return s.ch
+}
+
+// Lagged reports, and clears, whether changes were dropped since the last call
+func (s *Subscription) Lagged() bool {
+	return s.lagged.Swap(false)
+}
+
+// Close unsubscribes. The channel is not closed, so a concurrent Publish
+// can never send on a closed channel.
+func (s *Subscription) Close() {
+	s.feed.mu.Lock()
+	delete(s.feed.subs, s)
+	s.feed.mu.Unlock()
+}
+
+// RankChange describes one user in a top-N diff. Old values are set for
+// moves and rating changes.
+type RankChange struct {
+	Username  string `json:"username"`
+	Rating    int64  `json:"rating"`
+	Rank      int    `json:"rank"`
+	OldRating *int64 `json:"old_rating,omitempty"`
+	OldRank   *int   `json:"old_rank,omitempty"`
+}
+
+// TopNDiff is what changed between two snapshots of the top of a board
+type TopNDiff struct {
+	Entered       []RankChange `json:"entered,omitempty"`
+	Left          []RankChange `json:"left,omitempty"` // last known rating and rank
+	Moved         []RankChange `json:"moved,omitempty"`
+	RatingChanged []RankChange `json:"rating_changed,omitempty"`
+}
+
+// Empty reports whether the snapshots were identical
+func (d TopNDiff) Empty() bool {
+	return len(d.Entered) == 0 && len(d.Left) == 0 && len(d.Moved) == 0 && len(d.RatingChanged) == 0
+}
+
+// DiffTopN compares two GetTopN snapshots. A user whose rank and rating
+// both changed is listed under Moved and RatingChanged.
+func DiffTopN(prev, cur []RankedUser) TopNDiff {
+	before := make(map[string]RankedUser, len(prev))
+	for _, u := range prev {
+		before[u.Username] = u
+	}
+
+	var d TopNDiff
+	for _, u := range cur {
+		old, ok := before[u.Username]
+		delete(before, u.Username)
+		if !ok {
+			d.Entered = append(d.Entered, RankChange{Username: u.Username, Rating: u.Rating, Rank: u.Rank})
+			continue
+		}
+		change := RankChange{Username: u.Username, Rating: u.Rating, Rank: u.Rank, OldRating: &old.Rating, OldRank: &old.Rank}
+		if old.Rank != u.Rank {
+			d.Moved = append(d.Moved, change)
+		}
+		if old.Rating != u.Rating {
+			d.RatingChanged = append(d.RatingChanged, change)
+		}
+	}
+	// Walk prev rather than the map so Left keeps rank order
+	for _, u := range prev {
+		if _, ok := before[u.Username]; ok {
+			d.Left = append(d.Left, RankChange{Username: u.Username, Rating: u.Rating, Rank: u.Rank})
+		}
+	}
+	return d
+}
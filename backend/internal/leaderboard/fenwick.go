@@ -5,6 +5,7 @@ package leaderboard
 type FenwickTree struct {
 	tree []int
 	n    int
+	step int // largest power of two <= n, the first FindKth jump
 }
 
 // NewFenwickTree creates a tree for the rating range
@@ -12,9 +13,19 @@ func NewFenwickTree(size int) *FenwickTree {
 	return &FenwickTree{
 		tree: make([]int, size+1), // 1-indexed
 		n:    size,
+		step: highestPowerOfTwo(size),
 	}
 }
 
+// highestPowerOfTwo returns the largest power of two <= n (0 for n < 1)
+func highestPowerOfTwo(n int) int {
+	p := 0
+	for i := 1; i <= n; i <<= 1 {
+		p = i
+	}
+	return p
+}
+
 // Update adds delta to position i (1-indexed)
 // Called when user rating changes: +1 for new rating, -1 for old
 func (ft *FenwickTree) Update(i, delta int) {
@@ -45,3 +56,19 @@ func (ft *FenwickTree) RangeSum(l, r int) int {
 	}
 	return ft.PrefixSum(r) - ft.PrefixSum(l-1)
 }
+
+// FindKth returns the smallest index i with PrefixSum(i) >= k, i.e. the
+// position of the k-th counted element in ascending order.
+// It walks the tree by binary lifting in O(log n) and assumes all point
+// values are non-negative. Returns n+1 if the total is less than k.
+func (ft *FenwickTree) FindKth(k int) int {
+	pos := 0
+	for step := ft.step; step > 0; step >>= 1 {
+		next := pos + step
+		if next <= ft.n && ft.tree[next] < k {
+			pos = next
+			k -= ft.tree[next] // Skip the whole block covered by next
+		}
+	}
+	return pos + 1
+}
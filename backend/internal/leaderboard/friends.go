@@ -0,0 +1,64 @@
+package leaderboard
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrInvalidFriend  = errors.New("invalid friend")
+	ErrFriendNotFound = errors.New("not a friend")
+)
+
+// MaxFriends caps how many users one user may follow
+const MaxFriends = 1000
+
+// Friend is one entry of a user's friend list. Friendship is one-way:
+// a user follows friends without their consent, like a watch list.
+type Friend struct {
+	Username string    `json:"username"`
+	Since    time.Time `json:"since"`
+}
+
+// Friends is implemented by Boards registries that keep a social graph.
+// The graph is shared by every board.
+type Friends interface {
+	AddFriend(username, friend string) error
+	RemoveFriend(username, friend string) error
+	ListFriends(username string) ([]Friend, error)
+}
+
+// FriendRank is a user's standing among a friend group. The embedded
+// RankedUser carries the global rank.
+type FriendRank struct {
+	RankedUser
+	FriendRank int `json:"friend_rank"`
+}
+
+// FriendStore is implemented by stores that can rank a user's friends
+type FriendStore interface {
+	// FriendsLeaderboard ranks the user and the friends who play on the
+	// board, best first
+	FriendsLeaderboard(username string) ([]FriendRank, error)
+}
+
+var (
+	_ Friends     = (*PostgresBoards)(nil)
+	_ FriendStore = (*PostgresStore)(nil)
+)
+
+// rankAmong sorts users into leaderboard order and ranks them against each
+// other under the board's ranking mode
+func rankAmong(users []RankedUser, cfg BoardConfig) []FriendRank {
+	sort.Slice(users, func(i, j int) bool {
+		return cfg.listedBefore(users[i].User, users[j].User)
+	})
+
+	walk := rankWalk{mode: cfg.RankingMode}
+	ranked := make([]FriendRank, len(users))
+	for i, u := range users {
+		ranked[i] = FriendRank{RankedUser: u, FriendRank: walk.next(u.Rating)}
+	}
+	return ranked
+}
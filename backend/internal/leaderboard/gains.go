@@ -0,0 +1,128 @@
+package leaderboard
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidWindow  = errors.New("invalid window")
+	ErrWindowNotFound = errors.New("window not found")
+)
+
+// Window kinds
+const (
+	WindowDaily   = "daily"
+	WindowWeekly  = "weekly"
+	WindowMonthly = "monthly"
+)
+
+// WindowKinds lists every window kind in rollover order
+var WindowKinds = []string{WindowDaily, WindowWeekly, WindowMonthly}
+
+// windowRetention is how many closed calendar windows of each kind keep
+// their archived standings
+var windowRetention = map[string]int{
+	WindowDaily:   31,
+	WindowWeekly:  26,
+	WindowMonthly: 24,
+}
+
+// rollingLength is the span of a rolling window of each kind
+var rollingLength = map[string]time.Duration{
+	WindowDaily:   24 * time.Hour,
+	WindowWeekly:  7 * 24 * time.Hour,
+	WindowMonthly: 30 * 24 * time.Hour,
+}
+
+// Window is a time range [Start, End) that rating gains are summed over.
+// Calendar windows are UTC days, Monday-based weeks and months; rolling
+// windows end at the time they were asked for.
+type Window struct {
+	Kind    string    `json:"kind"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Rolling bool      `json:"rolling,omitempty"`
+}
+
+// CalendarWindow returns the calendar window of kind that contains t
+func CalendarWindow(kind string, t time.Time) (Window, error) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	w := Window{Kind: kind}
+	switch kind {
+	case WindowDaily:
+		w.Start = day
+		w.End = day.AddDate(0, 0, 1)
+	case WindowWeekly:
+		sinceMonday := (int(day.Weekday()) + 6) % 7
+		w.Start = day.AddDate(0, 0, -sinceMonday)
+		w.End = w.Start.AddDate(0, 0, 7)
+	case WindowMonthly:
+		w.Start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		w.End = w.Start.AddDate(0, 1, 0)
+	default:
+		return Window{}, fmt.Errorf("%w: kind must be %q, %q or %q", ErrInvalidWindow, WindowDaily, WindowWeekly, WindowMonthly)
+	}
+	return w, nil
+}
+
+// RollingWindow returns the window of kind's length that ends at t.
+// Monthly rolling windows are 30 days.
+func RollingWindow(kind string, t time.Time) (Window, error) {
+	length, ok := rollingLength[kind]
+	if !ok {
+		return Window{}, fmt.Errorf("%w: kind must be %q, %q or %q", ErrInvalidWindow, WindowDaily, WindowWeekly, WindowMonthly)
+	}
+	t = t.UTC()
+	return Window{Kind: kind, Start: t.Add(-length), End: t, Rolling: true}, nil
+}
+
+// Previous returns the calendar window just before w
+func (w Window) Previous() Window {
+	prev, _ := CalendarWindow(w.Kind, w.Start.Add(-time.Nanosecond))
+	return prev
+}
+
+// Closed reports whether the window had ended by now
+func (w Window) Closed(now time.Time) bool {
+	return !now.Before(w.End)
+}
+
+// oldestRetained returns the oldest closed window of kind still archived
+func oldestRetained(kind string, now time.Time) Window {
+	w, _ := CalendarWindow(kind, now)
+	for i := 0; i < windowRetention[kind]; i++ {
+		w = w.Previous()
+	}
+	return w
+}
+
+// retained reports whether a calendar window's standings are still kept
+func (w Window) retained(now time.Time) bool {
+	return !w.Start.Before(oldestRetained(w.Kind, now).Start)
+}
+
+// WindowRank is a user's standing by rating gained within a window.
+// Rating is the user's rating at the end of the window.
+type WindowRank struct {
+	Username string `json:"username"`
+	Gain     int64  `json:"gain"`
+	Rating   int64  `json:"rating"`
+	Rank     int    `json:"rank"`
+}
+
+// WindowStore is implemented by stores that rank rating gains over time
+type WindowStore interface {
+	// WindowLeaderboard ranks users whose rating changed within w by the
+	// rating they gained, biggest climbers first, and returns how many
+	// users are ranked. Season resets do not count as gains.
+	WindowLeaderboard(w Window, limit, offset int) ([]WindowRank, int, error)
+	// RollOverWindows archives the standings of calendar windows closed by
+	// now and drops those past retention
+	RollOverWindows(now time.Time) error
+}
+
+var _ WindowStore = (*PostgresStore)(nil)
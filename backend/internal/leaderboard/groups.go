@@ -0,0 +1,118 @@
+package leaderboard
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrGroupNotFound  = errors.New("group not found")
+	ErrGroupExists    = errors.New("group already exists")
+	ErrInvalidGroup   = errors.New("invalid group")
+	ErrAlreadyInGroup = errors.New("user already belongs to a group")
+	ErrNotGroupMember = errors.New("user is not a member of the group")
+)
+
+var groupNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _-]{0,63}$`)
+
+// Group aggregation modes
+const (
+	AggregateSum  = "sum"  // total rating; big groups rank higher
+	AggregateAvg  = "avg"  // mean rating of all members
+	AggregateTopK = "topk" // mean rating of the best K members
+)
+
+// DefaultTopK is K for top-K aggregation when none is given
+const DefaultTopK = 5
+
+// Group is a clan of players. Membership is shared by every board; a user
+// belongs to at most one group.
+type Group struct {
+	Name      string    `json:"name"`
+	Members   int       `json:"members"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GroupAggregate chooses how member ratings combine into a group's score
+type GroupAggregate struct {
+	Mode string `json:"mode"`
+	K    int    `json:"k,omitempty"` // topk only
+}
+
+// WithDefaults fills in the mode and K when left unset
+func (a GroupAggregate) WithDefaults() GroupAggregate {
+	if a.Mode == "" {
+		a.Mode = AggregateAvg
+	}
+	if a.Mode == AggregateTopK && a.K == 0 {
+		a.K = DefaultTopK
+	}
+	return a
+}
+
+func (a GroupAggregate) Validate() error {
+	switch a.Mode {
+	case AggregateSum, AggregateAvg:
+		if a.K != 0 {
+			return fmt.Errorf("%w: k only applies to %s", ErrInvalidGroup, AggregateTopK)
+		}
+		return nil
+	case AggregateTopK:
+		if a.K <= 0 {
+			return fmt.Errorf("%w: k must be positive", ErrInvalidGroup)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown aggregate %q", ErrInvalidGroup, a.Mode)
+	}
+}
+
+// validateGroupName checks a group name is usable in URLs and logs
+func validateGroupName(name string) error {
+	if !groupNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: name must match %s", ErrInvalidGroup, groupNamePattern)
+	}
+	return nil
+}
+
+// RankedGroup is a group's standing on one board. Members counts only
+// members who play on the board.
+type RankedGroup struct {
+	Name    string  `json:"name"`
+	Members int     `json:"members"`
+	Score   float64 `json:"score"`
+	Rank    int     `json:"rank"`
+}
+
+// MemberRank is a member's standing within their group. The embedded
+// RankedUser carries the board-wide rank.
+type MemberRank struct {
+	RankedUser
+	GroupRank int `json:"group_rank"`
+}
+
+// Groups is implemented by Boards registries that keep groups
+type Groups interface {
+	CreateGroup(name string) (*Group, error)
+	DeleteGroup(name string) error
+	ListGroups() ([]Group, error)
+	JoinGroup(group, username string) error
+	LeaveGroup(group, username string) error
+}
+
+// GroupStore is implemented by stores that can rank groups
+type GroupStore interface {
+	// GroupLeaderboard pages through groups by score, best first, and
+	// returns the number of ranked groups
+	GroupLeaderboard(agg GroupAggregate, limit, offset int) ([]RankedGroup, int, error)
+	GetGroupRank(name string, agg GroupAggregate) (*RankedGroup, error)
+	// GroupMembers pages through the group's members who play on the board
+	GroupMembers(name string, limit, offset int) ([]MemberRank, error)
+}
+
+var (
+	_ Groups     = (*PostgresBoards)(nil)
+	_ GroupStore = (*PostgresStore)(nil)
+)
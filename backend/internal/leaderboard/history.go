@@ -0,0 +1,43 @@
+package leaderboard
+
+import "time"
+
+// Source identifies what caused a rating change
+type Source string
+
+const (
+	SourceAPI       Source = "api"
+	SourceSimulator Source = "simulator"
+	SourceMatch     Source = "match"
+	SourceSeason    Source = "season"
+	SourceBulk      Source = "bulk"
+	// Published on the change feed only
+	SourceSeed Source = "seed" // bulk seeding
+	SourceSync Source = "sync" // rank cache resynced with the database
+)
+
+// RatingChange is one entry of a user's rating history
+type RatingChange struct {
+	OldRating *int64    `json:"old_rating"` // nil when the user was created
+	NewRating int64     `json:"new_rating"`
+	Rank      int       `json:"rank"` // rank right after the change
+	Source    Source    `json:"source"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// RatingHistory is a user's rating time series within a window.
+// Peaks cover the user's whole history, not just the window.
+type RatingHistory struct {
+	Username   string         `json:"username"`
+	Changes    []RatingChange `json:"changes"`
+	PeakRating int64          `json:"peak_rating"`
+	PeakRank   int            `json:"peak_rank"`
+}
+
+// HistoryStore is implemented by stores that record rating changes
+type HistoryStore interface {
+	// RatingHistory returns changes in [from, to); zero times leave that end open
+	RatingHistory(username string, from, to time.Time) (*RatingHistory, error)
+}
+
+var _ HistoryStore = (*PostgresStore)(nil)
@@ -0,0 +1,81 @@
+package leaderboard
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrImportConflict = errors.New("import has conflicts")
+
+// Import conflict modes decide what happens to users that already exist
+const (
+	ConflictSkip      = "skip"      // keep the existing user, import the rest
+	ConflictOverwrite = "overwrite" // replace the existing user's rating
+	ConflictFail      = "fail"      // import nothing unless every row applies cleanly
+)
+
+// ImportResult reports every row's outcome. Applied is false for dry runs
+// and for failed imports, whose rows report what would have happened.
+type ImportResult struct {
+	Conflict string       `json:"conflict"`
+	DryRun   bool         `json:"dry_run"`
+	Applied  bool         `json:"applied"`
+	Results  []BulkResult `json:"results"`
+}
+
+// importMode maps a conflict mode to the bulk mode that applies it
+func importMode(conflict string) (string, error) {
+	switch conflict {
+	case ConflictSkip, ConflictFail:
+		return BulkInsert, nil
+	case ConflictOverwrite:
+		return BulkUpsert, nil
+	default:
+		return "", fmt.Errorf("%w: conflict must be %q, %q or %q", ErrInvalidBulk, ConflictSkip, ConflictOverwrite, ConflictFail)
+	}
+}
+
+// importConflict returns ErrImportConflict naming the first row that
+// would not be created, or nil if every row would be
+func importConflict(results []BulkResult) error {
+	for _, r := range results {
+		if r.Status != BulkCreated {
+			return fmt.Errorf("%w: row %d (%s) is %s", ErrImportConflict, r.Index, r.Username, r.Status)
+		}
+	}
+	return nil
+}
+
+// Import loads rows into the board under a conflict mode. A dry run
+// validates the rows against the board bounds and current users without
+// writing. Under ConflictFail the rows are written with InsertAll, so
+// nothing is written if any of them is invalid or exists, in which case
+// the result comes with ErrImportConflict.
+func Import(lb Store, rows []BulkRow, conflict string, dryRun bool) (*ImportResult, error) {
+	mode, err := importMode(conflict)
+	if err != nil {
+		return nil, err
+	}
+	result := &ImportResult{Conflict: conflict, DryRun: dryRun}
+
+	switch {
+	case dryRun:
+		result.Results, err = lb.PlanBulk(rows, mode)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	case conflict == ConflictFail:
+		result.Results, err = lb.InsertAll(rows)
+		if errors.Is(err, ErrImportConflict) {
+			return result, err
+		}
+	default:
+		result.Results, err = lb.BulkWrite(rows, mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Applied = true
+	return result, nil
+}
@@ -1,248 +1,371 @@
 package leaderboard
 
 import (
-	"database/sql"
 	"errors"
-	"fmt"
-	"log"
+	"sort"
+	"strings"
+	"sync"
 	"time"
-
-	"github.com/brianvoe/gofakeit/v6"
-	_ "github.com/lib/pq"
 )
 
 var (
-	ErrUserNotFound  = errors.New("user not found")
-	ErrUserExists    = errors.New("user already exists")
-	ErrInvalidRating = errors.New("rating must be between 100 and 5000")
+	ErrUserNotFound   = errors.New("user not found")
+	ErrUserExists     = errors.New("user already exists")
+	ErrInvalidRating  = errors.New("rating out of range")
+	ErrRankOutOfRange = errors.New("rank out of range")
 )
 
-// Leaderboard manages users in Postgres
+// Leaderboard is an in-memory Store for a single board.
+// Ranks come from a ScoreTree over the ratings in use, so a rank lookup
+// is O(log n) no matter how many users share the board or how wide its
+// rating range is. An ordinal rank also orders the t users tied on the
+// rating, for O(log n + t); listings take ordinal ranks from position.
 type Leaderboard struct {
-	db *sql.DB
+	cfg      BoardConfig
+	mu       sync.RWMutex
+	users    map[string]int64
+	ties     map[string]time.Time // tie-break keys; empty under username tie-break
+	byRating map[int64]map[string]struct{}
+	scores   *ScoreTree
+	feed     *Feed
+}
+
+// NewLeaderboard creates an empty in-memory leaderboard with the default bounds
+func NewLeaderboard() *Leaderboard {
+	return NewLeaderboardFor(DefaultBoardConfig())
 }
 
-// NewLeaderboard connects to Postgres and ensures schema exists
-func NewLeaderboard(dsn string) (*Leaderboard, error) {
-	db, err := sql.Open("postgres", dsn)
-	if err != nil {
-		return nil, err
+// NewLeaderboardFor creates an empty in-memory leaderboard for a board
+func NewLeaderboardFor(cfg BoardConfig) *Leaderboard {
+	return &Leaderboard{
+		cfg:      cfg,
+		users:    make(map[string]int64),
+		ties:     make(map[string]time.Time),
+		byRating: make(map[int64]map[string]struct{}),
+		scores:   cfg.newScoreTree(),
+		feed:     NewFeed(cfg.Name),
 	}
+}
+
+// Config returns the board this leaderboard serves
+func (lb *Leaderboard) Config() BoardConfig {
+	return lb.cfg
+}
+
+func (lb *Leaderboard) Changes() *Feed {
+	return lb.feed
+}
 
-	if err := db.Ping(); err != nil {
-		return nil, err
+// rankOf returns u's rank under the board's ranking mode.
+// Caller must hold lb.mu.
+func (lb *Leaderboard) rankOf(u User) int {
+	switch lb.cfg.RankingMode {
+	case RankDense:
+		return lb.scores.DistinctAhead(u.Rating) + 1
+	case RankOrdinal:
+		rank := lb.scores.Ahead(u.Rating) + 1
+		for name := range lb.byRating[u.Rating] {
+			if lb.cfg.listedBefore(lb.user(name), u) {
+				rank++
+			}
+		}
+		return rank
+	default:
+		return lb.scores.Ahead(u.Rating) + 1
 	}
+}
 
-	// OPTIMIZATION: Tune Connection Pool
-	// SetMaxOpenConns: Max concurrent connections to the DB.
-	// 25 is a good starting point for Azure/Cloud Postgres small instances.
-	db.SetMaxOpenConns(25)
-	// SetMaxIdleConns: Keep these ready to avoid handshake latency.
-	db.SetMaxIdleConns(25)
-	// SetConnMaxLifetime: Recycle connections to prevent stale timeouts.
-	db.SetConnMaxLifetime(5 * time.Minute)
+// ranked returns username's current rating and rank. Caller must hold
+// lb.mu and know the user exists.
+func (lb *Leaderboard) ranked(username string) *RankedUser {
+	u := lb.user(username)
+	return &RankedUser{User: u, Rank: lb.rankOf(u)}
+}
 
-	lb := &Leaderboard{db: db}
-	if err := lb.initSchema(); err != nil {
-		return nil, err
+// user returns username's current rating and tie key. Caller must hold lb.mu.
+func (lb *Leaderboard) user(username string) User {
+	tie, ok := lb.ties[username]
+	if !ok {
+		tie = noTie
 	}
+	return User{Username: username, Rating: lb.users[username], tie: tie}
+}
 
-	return lb, nil
+// move changes an existing user's rating. Caller must hold lb.mu.
+func (lb *Leaderboard) move(username string, newRating int64) {
+	old := lb.users[username]
+	lb.users[username] = newRating
+	lb.scores.Add(old, -1)
+	lb.scores.Add(newRating, 1)
+	lb.unindex(username, old)
+	lb.index(username, newRating)
+	if lb.cfg.TieBreak == TieBreakAchieved && newRating != old {
+		lb.ties[username] = time.Now()
+	}
 }
 
-func (lb *Leaderboard) initSchema() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS users (
-		username VARCHAR(255) PRIMARY KEY,
-		rating INTEGER NOT NULL CHECK (rating >= 100 AND rating <= 5000),
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-	CREATE INDEX IF NOT EXISTS idx_rating ON users(rating DESC);
-	-- OPTIMIZATION: Index for fast case-insensitive prefix search
-	CREATE INDEX IF NOT EXISTS idx_username_lower ON users(lower(username) varchar_pattern_ops);
-	`
-	_, err := lb.db.Exec(query)
-	return err
+func (lb *Leaderboard) AddUser(username string, rating int64) error {
+	if err := lb.addUser(username, rating); err != nil {
+		return err
+	}
+	lb.feed.publishUser(username, nil, &rating, SourceAPI)
+	return nil
 }
 
-func (lb *Leaderboard) AddUser(username string, rating int) error {
-	if rating < MinRating || rating > MaxRating {
-		return ErrInvalidRating
+// addUser inserts a user without publishing the change
+func (lb *Leaderboard) addUser(username string, rating int64) error {
+	if err := lb.cfg.checkRating(rating); err != nil {
+		return err
 	}
 
-	_, err := lb.db.Exec("INSERT INTO users (username, rating) VALUES ($1, $2)", username, rating)
-	if err != nil {
-		// Simple check for duplicate key error
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if _, ok := lb.users[username]; ok {
 		return ErrUserExists
 	}
+	lb.insert(username, rating)
 	return nil
 }
 
-func (lb *Leaderboard) UpdateRating(username string, newRating int) error {
-	if newRating < MinRating || newRating > MaxRating {
-		return ErrInvalidRating
+// insert adds a new user. Caller must hold lb.mu.
+func (lb *Leaderboard) insert(username string, rating int64) {
+	lb.users[username] = rating
+	lb.scores.Add(rating, 1)
+	lb.index(username, rating)
+	if lb.cfg.TieBreak != TieBreakUsername {
+		lb.ties[username] = time.Now()
+	}
+}
+
+// index lists username among the users holding rating. Caller must hold lb.mu.
+func (lb *Leaderboard) index(username string, rating int64) {
+	names, ok := lb.byRating[rating]
+	if !ok {
+		names = make(map[string]struct{})
+		lb.byRating[rating] = names
+	}
+	names[username] = struct{}{}
+}
+
+// unindex reverses index. Caller must hold lb.mu.
+func (lb *Leaderboard) unindex(username string, rating int64) {
+	delete(lb.byRating[rating], username)
+	if len(lb.byRating[rating]) == 0 {
+		delete(lb.byRating, rating)
 	}
+}
+
+// rankAt returns the rank of sorted[i], where sorted is the whole board in
+// leaderboard order, so ordinal ranks need no tie scan. Caller must hold lb.mu.
+func (lb *Leaderboard) rankAt(sorted []User, i int) int {
+	if lb.cfg.RankingMode == RankOrdinal {
+		return i + 1
+	}
+	return lb.rankOf(sorted[i])
+}
+
+func (lb *Leaderboard) UpdateRating(username string, newRating int64) error {
+	return lb.SetRating(username, newRating, SourceAPI)
+}
 
-	res, err := lb.db.Exec("UPDATE users SET rating = $1 WHERE username = $2", newRating, username)
-	if err != nil {
+// SetRating overwrites a user's rating. The in-memory engine keeps no
+// history, so source is ignored.
+func (lb *Leaderboard) SetRating(username string, newRating int64, source Source) error {
+	if err := lb.cfg.checkRating(newRating); err != nil {
 		return err
 	}
 
-	rows, _ := res.RowsAffected()
-	if rows == 0 {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	old, ok := lb.users[username]
+	if !ok {
 		return ErrUserNotFound
 	}
+	lb.move(username, newRating)
+	lb.feed.publishUser(username, &old, &newRating, source)
 	return nil
 }
 
-func (lb *Leaderboard) GetUserRank(username string) (*RankedUser, error) {
-	var u User
-	err := lb.db.QueryRow("SELECT username, rating FROM users WHERE username = $1", username).Scan(&u.Username, &u.Rating)
-	if err == sql.ErrNoRows {
+// IncrementRating moves a rating by delta under the board lock, clamped to
+// the board bounds. The in-memory engine keeps no history, so source is ignored.
+func (lb *Leaderboard) IncrementRating(username string, delta int64, source Source) (*RankedUser, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	old, ok := lb.users[username]
+	if !ok {
 		return nil, ErrUserNotFound
-	} else if err != nil {
-		return nil, err
 	}
+	rating := addClamped(old, delta, lb.cfg.MinRating, lb.cfg.MaxRating)
+	lb.move(username, rating)
+	lb.feed.publishUser(username, &old, &rating, source)
+	return lb.ranked(username), nil
+}
+
+func (lb *Leaderboard) DeleteUser(username string) error {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	rating, ok := lb.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+	delete(lb.users, username)
+	delete(lb.ties, username)
+	lb.scores.Add(rating, -1)
+	lb.unindex(username, rating)
+	lb.feed.publishUser(username, &rating, nil, SourceAPI)
+	return nil
+}
+
+func (lb *Leaderboard) GetUserRank(username string) (*RankedUser, error) {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
 
-	// Calculate rank: 1 + count of users with rating > u.Rating
-	var rank int
-	err = lb.db.QueryRow("SELECT COUNT(*) + 1 FROM users WHERE rating > $1", u.Rating).Scan(&rank)
-	if err != nil {
-		return nil, err
+	if _, ok := lb.users[username]; !ok {
+		return nil, ErrUserNotFound
 	}
+	return lb.ranked(username), nil
+}
 
-	return &RankedUser{
-		User: u,
-		Rank: rank,
-	}, nil
+// RatingAtRank returns the rating held by the user in position rank,
+// i.e. the worst rating that still reaches rank or better
+func (lb *Leaderboard) RatingAtRank(rank int) (int64, error) {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+
+	kth := lb.scores.Kth
+	if lb.cfg.RankingMode == RankDense {
+		// Dense rank r is held by the r-th best distinct rating
+		kth = lb.scores.KthDistinct
+	}
+	if rank < 1 {
+		return 0, ErrRankOutOfRange
+	}
+	rating, ok := kth(rank)
+	if !ok {
+		return 0, ErrRankOutOfRange
+	}
+	return rating, nil
 }
 
 func (lb *Leaderboard) SearchUsers(query string, limit int) []RankedUser {
-	// Search by prefix
-	rows, err := lb.db.Query(`
-		SELECT username, rating,
-		(SELECT COUNT(*) + 1 FROM users u2 WHERE u2.rating > users.rating) as rank
-		FROM users
-		WHERE username ILIKE $1 || '%'
-		ORDER BY rank ASC, username ASC
-		LIMIT $2`, query, limit)
-
-	if err != nil {
-		log.Println("Search error:", err)
-		return []RankedUser{}
-	}
-	defer rows.Close()
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
 
+	prefix := strings.ToLower(query)
 	var results []RankedUser
-	for rows.Next() {
-		var r RankedUser
-		if err := rows.Scan(&r.Username, &r.Rating, &r.Rank); err != nil {
+	sorted := lb.sorted()
+	for i, u := range sorted {
+		if !strings.HasPrefix(strings.ToLower(u.Username), prefix) {
 			continue
 		}
-		results = append(results, r)
+		results = append(results, RankedUser{User: u, Rank: lb.rankAt(sorted, i)})
+		if len(results) == limit {
+			break
+		}
 	}
 	return results
 }
 
 func (lb *Leaderboard) GetTopN(limit, offset int) []RankedUser {
-	// Use window function for efficient ranking in one query
-	// RANK() gives standard competition ranking (1, 1, 3) which matches "count > rating + 1" logic
-	query := `
-		SELECT username, rating, rank FROM (
-			SELECT username, rating,
-			RANK() OVER (ORDER BY rating DESC) as rank
-			FROM users
-		) sub
-		ORDER BY rank ASC, username ASC
-		LIMIT $1 OFFSET $2
-	`
-
-	rows, err := lb.db.Query(query, limit, offset)
-	if err != nil {
-		log.Println("TopN error:", err)
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+
+	sorted := lb.sorted()
+	if offset >= len(sorted) {
 		return []RankedUser{}
 	}
-	defer rows.Close()
+	end := offset + limit
+	if end > len(sorted) {
+		end = len(sorted)
+	}
 
 	var results []RankedUser
-	for rows.Next() {
-		var r RankedUser
-		if err := rows.Scan(&r.Username, &r.Rating, &r.Rank); err != nil {
-			continue
-		}
-		results = append(results, r)
+	for i := offset; i < end; i++ {
+		results = append(results, RankedUser{User: sorted[i], Rank: lb.rankAt(sorted, i)})
 	}
 	return results
 }
 
-func (lb *Leaderboard) GetStats() LeaderboardStats {
-	var stats LeaderboardStats
-
-	lb.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&stats.TotalUsers)
-	lb.db.QueryRow("SELECT COUNT(DISTINCT rating) FROM users").Scan(&stats.UniqueRatings)
-	lb.db.QueryRow("SELECT COALESCE(MAX(rating), 0) FROM users").Scan(&stats.HighestRating)
-	lb.db.QueryRow("SELECT COALESCE(MIN(rating), 0) FROM users").Scan(&stats.LowestRating)
+func (lb *Leaderboard) GetPage(cursor Cursor, limit int) (*Page, error) {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
 
-	return stats
+	sorted := lb.sorted()
+	start, end, page := pageOf(lb.cfg, sorted, cursor, limit)
+	page.Users = make([]RankedUser, 0, end-start)
+	for i := start; i < end; i++ {
+		page.Users = append(page.Users, RankedUser{User: sorted[i], Rank: lb.rankAt(sorted, i)})
+	}
+	return &page, nil
 }
 
-func (lb *Leaderboard) Count() int {
-	var count int
-	lb.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
-	return count
-}
+func (lb *Leaderboard) GetAround(username string, radius int) ([]RankedUser, error) {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
 
-func (lb *Leaderboard) Seed(count int, clear bool) {
-	if clear {
-		lb.db.Exec("TRUNCATE TABLE users")
+	if _, ok := lb.users[username]; !ok {
+		return nil, ErrUserNotFound
 	}
 
-	gofakeit.Seed(time.Now().UnixNano())
-
-	// Batch insert logic
-	batchSize := 500
-	for i := 0; i < count; i += batchSize {
-		end := i + batchSize
-		if end > count {
-			end = count
-		}
-
-		tx, err := lb.db.Begin()
-		if err != nil {
-			log.Printf("Seed batch tx error at %d: %v", i, err)
-			return
-		}
+	me := lb.user(username)
+	sorted := lb.sorted()
+	pos := sort.Search(len(sorted), func(i int) bool {
+		return !lb.cfg.listedBefore(sorted[i], me)
+	})
+	start, end := pos-radius, pos+radius+1
+	if start < 0 {
+		start = 0
+	}
+	if end > len(sorted) {
+		end = len(sorted)
+	}
 
-		stmt, err := tx.Prepare("INSERT INTO users (username, rating) VALUES ($1, $2) ON CONFLICT DO NOTHING")
-		if err != nil {
-			log.Printf("Seed batch prep error at %d: %v", i, err)
-			tx.Rollback()
-			return
-		}
+	results := make([]RankedUser, 0, end-start)
+	for i := start; i < end; i++ {
+		results = append(results, RankedUser{User: sorted[i], Rank: lb.rankAt(sorted, i)})
+	}
+	return results, nil
+}
 
-		for j := i; j < end; j++ {
-			username := gofakeit.Username()
-			username = fmt.Sprintf("%s_%d", username, gofakeit.Number(1, 99999))
-			rating := gofakeit.Number(MinRating, MaxRating)
+// sorted returns all users in leaderboard order.
+// This is O(n log n) per call, which is fine for the in-memory engine's
+// intended use (tests and local development). Caller must hold lb.mu.
+func (lb *Leaderboard) sorted() []User {
+	users := make([]User, 0, len(lb.users))
+	for name := range lb.users {
+		users = append(users, lb.user(name))
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return lb.cfg.listedBefore(users[i], users[j])
+	})
+	return users
+}
 
-			if _, err := stmt.Exec(username, rating); err != nil {
-				continue
-			}
+func (lb *Leaderboard) GetStats() LeaderboardStats {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+
+	stats := LeaderboardStats{TotalUsers: len(lb.users)}
+	seen := make(map[int64]struct{})
+	for _, rating := range lb.users {
+		if len(seen) == 0 || rating > stats.HighestRating {
+			stats.HighestRating = rating
 		}
-
-		stmt.Close()
-		if err := tx.Commit(); err != nil {
-			log.Printf("Seed batch commit error at %d: %v", i, err)
-		} else {
-			// Log progress every 1000 users or so
-			if (i+batchSize)%1000 == 0 || end == count {
-				log.Printf("Seeded %d/%d users...", end, count)
-			}
+		if len(seen) == 0 || rating < stats.LowestRating {
+			stats.LowestRating = rating
 		}
+		seen[rating] = struct{}{}
 	}
+	stats.UniqueRatings = len(seen)
+	return stats
 }
 
-// Close closes the db connection
-func (lb *Leaderboard) Close() error {
-	return lb.db.Close()
+func (lb *Leaderboard) Count() int {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	return len(lb.users)
 }
@@ -1,7 +1,14 @@
 package leaderboard
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestFenwickTree(t *testing.T) {
@@ -143,18 +150,1340 @@ func TestLeaderboard_UpdateRating(t *testing.T) {
 		t.Errorf("Rating = %d; want 2000", u.Rating)
 	}
 
-	// Fenwick check (internal)
-	// Index for 2000 should have 1, Index for 1000 should have 0
-	// 1000 -> Index 1000-100+1 = 901
-	// 2000 -> Index 2000-100+1 = 1901
+	// Score tree check (internal)
+	// 1000 should have no users left, 2000 should have one
 
-	count1000 := lb.fenwick.RangeSum(901, 901)
+	count1000 := lb.scores.CountAt(1000)
 	if count1000 != 0 {
-		t.Errorf("Fenwick count at 1000 = %d; want 0", count1000)
+		t.Errorf("Score tree count at 1000 = %d; want 0", count1000)
 	}
 
-	count2000 := lb.fenwick.RangeSum(1901, 1901)
+	count2000 := lb.scores.CountAt(2000)
 	if count2000 != 1 {
-		t.Errorf("Fenwick count at 2000 = %d; want 1", count2000)
+		t.Errorf("Score tree count at 2000 = %d; want 1", count2000)
+	}
+}
+
+func TestRankCache(t *testing.T) {
+	c := newRankCache(DefaultBoardConfig())
+	c.load(map[int64]int{1000: 2, 1500: 1})
+
+	if rank := c.rank(1500); rank != 1 {
+		t.Errorf("rank(1500) = %d; want 1", rank)
+	}
+	if rank := c.rank(1000); rank != 2 {
+		t.Errorf("rank(1000) = %d; want 2", rank)
+	}
+
+	// One of the 1000s climbs past the leader
+	c.move(1000, 2000)
+	if rank := c.rank(1500); rank != 2 {
+		t.Errorf("rank(1500) after move = %d; want 2", rank)
+	}
+
+	c.add(100)
+	stats := c.stats()
+	if stats.TotalUsers != 4 || stats.UniqueRatings != 4 || stats.HighestRating != 2000 || stats.LowestRating != 100 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	want := map[int64]int{100: 1, 1000: 1, 1500: 1, 2000: 1}
+	if !sameHistogram(c.histogram(), want) {
+		t.Errorf("histogram = %v; want %v", c.histogram(), want)
+	}
+}
+
+func TestRankCache_LoadKeepsOutOfBoundsRatings(t *testing.T) {
+	// Rows left outside the bounds after a board's bounds were narrowed
+	hist := map[int64]int{MinRating - 50: 1, 1500: 2, MaxRating + 50: 1}
+	c := newRankCache(DefaultBoardConfig())
+	c.load(hist)
+
+	if !sameHistogram(c.histogram(), hist) {
+		t.Errorf("histogram = %v; want %v", c.histogram(), hist)
+	}
+	if rank := c.rank(1500); rank != 2 {
+		t.Errorf("rank(1500) = %d; want 2", rank)
+	}
+}
+
+func TestFenwickTree_FindKthMatchesBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for trial := 0; trial < 50; trial++ {
+		size := 1 + r.Intn(300)
+		ft := NewFenwickTree(size)
+		hist := make([]int, size+1)
+
+		for op := 0; op < 500; op++ {
+			i := 1 + r.Intn(size)
+			if hist[i] > 0 && r.Intn(3) == 0 {
+				ft.Update(i, -1)
+				hist[i]--
+			} else {
+				ft.Update(i, 1)
+				hist[i]++
+			}
+		}
+
+		total := 0
+		for _, n := range hist {
+			total += n
+		}
+
+		for k := 1; k <= total+1; k++ {
+			// Brute force: first index whose running sum reaches k
+			want, sum := size+1, 0
+			for i := 1; i <= size; i++ {
+				sum += hist[i]
+				if sum >= k {
+					want = i
+					break
+				}
+			}
+			if got := ft.FindKth(k); got != want {
+				t.Fatalf("trial %d size %d: FindKth(%d) = %d; want %d", trial, size, k, got, want)
+			}
+		}
+	}
+}
+
+func TestLeaderboard_RatingAtRankMatchesSortedOrder(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	lb := NewLeaderboard()
+
+	for i := 0; i < 2000; i++ {
+		lb.AddUser(fmt.Sprintf("u%d", i), MinRating+r.Int63n(RatingRange))
+	}
+
+	sorted := lb.GetTopN(lb.Count(), 0)
+	for i, u := range sorted {
+		rating, err := lb.RatingAtRank(i + 1)
+		if err != nil {
+			t.Fatalf("RatingAtRank(%d): %v", i+1, err)
+		}
+		if rating != u.Rating {
+			t.Fatalf("RatingAtRank(%d) = %d; want %d", i+1, rating, u.Rating)
+		}
+		// Anyone holding the cutoff rating must rank at or above the position
+		if u.Rank > i+1 {
+			t.Fatalf("user at position %d has rank %d", i+1, u.Rank)
+		}
+	}
+
+	if _, err := lb.RatingAtRank(0); err != ErrRankOutOfRange {
+		t.Errorf("RatingAtRank(0) err = %v; want ErrRankOutOfRange", err)
+	}
+	if _, err := lb.RatingAtRank(lb.Count() + 1); err != ErrRankOutOfRange {
+		t.Errorf("RatingAtRank(n+1) err = %v; want ErrRankOutOfRange", err)
+	}
+}
+
+func TestRankForPercentile(t *testing.T) {
+	total := 100
+	for _, p := range []float64{0.5, 1, 33.3, 50, 90, 99.9, 100} {
+		rank := RankForPercentile(total, p)
+		got := 100.0 * float64(total-rank+1) / float64(total)
+		if got < p {
+			t.Errorf("percentile %v: rank %d only reaches %v", p, rank, got)
+		}
+		// The next rank down must fall short, otherwise rank is not the lowest
+		if rank < total {
+			next := 100.0 * float64(total-rank) / float64(total)
+			if next >= p {
+				t.Errorf("percentile %v: rank %d is not the lowest qualifying rank", p, rank)
+			}
+		}
+	}
+}
+
+func TestSeasonReset_Validate(t *testing.T) {
+	cfg := DefaultBoardConfig()
+	cases := []struct {
+		reset SeasonReset
+		ok    bool
+	}{
+		{SeasonReset{Mode: ResetNone}, true},
+		{SeasonReset{Mode: ResetFresh}, true},
+		{SeasonReset{Mode: ResetSoft, Target: 1500, Factor: 50}, true},
+		{SeasonReset{Mode: ResetSoft, Target: 1500, Factor: 0}, false},
+		{SeasonReset{Mode: ResetSoft, Target: 1500, Factor: 120}, false},
+		{SeasonReset{Mode: ResetSoft, Target: 9000, Factor: 50}, false},
+		{SeasonReset{Mode: "hard"}, false},
+	}
+	for _, c := range cases {
+		err := c.reset.Validate(cfg)
+		if (err == nil) != c.ok {
+			t.Errorf("Validate(%+v) = %v; want ok=%v", c.reset, err, c.ok)
+		}
+	}
+}
+
+func TestRankCache_ProjectedRank(t *testing.T) {
+	c := newRankCache(DefaultBoardConfig())
+	c.load(map[int64]int{1000: 1, 1200: 1, 1500: 1})
+
+	// A newcomer at 1300 sits behind the 1500 only
+	if rank := c.projectedRank(nil, 1300); rank != 2 {
+		t.Errorf("projectedRank(nil, 1300) = %d; want 2", rank)
+	}
+
+	// The 1500 dropping to 1100 must not count its own old entry
+	old := int64(1500)
+	if rank := c.projectedRank(&old, 1100); rank != 2 {
+		t.Errorf("projectedRank(1500, 1100) = %d; want 2", rank)
+	}
+
+	// The 1000 climbing to 1300 passes the 1200
+	old = 1000
+	if rank := c.projectedRank(&old, 1300); rank != 2 {
+		t.Errorf("projectedRank(1000, 1300) = %d; want 2", rank)
+	}
+}
+
+func TestElo_Rate(t *testing.T) {
+	elo := Elo{K: 32}
+	rate := func(a, b, score float64) float64 {
+		return elo.Rate(PlayerRating{Rating: a}, []Outcome{{Opponent: PlayerRating{Rating: b}, Score: score}}).Rating - a
+	}
+
+	// Even players: the winner takes half of K
+	if d := rate(1500, 1500, 1); d != 16 {
+		t.Errorf("Rate(1500 beats 1500) delta = %v; want 16", d)
+	}
+	if d := rate(1500, 1500, 0.5); d != 0 {
+		t.Errorf("Rate(1500 draws 1500) delta = %v; want 0", d)
+	}
+	// A 400 point favourite is expected to score 10/11
+	if d := math.Round(rate(1900, 1500, 1)); d != 3 {
+		t.Errorf("Rate(1900 beats 1500) delta = %v; want 3", d)
+	}
+	if d := math.Round(rate(1500, 1900, 1)); d != 29 {
+		t.Errorf("Rate(1500 beats 1900) delta = %v; want 29", d)
+	}
+}
+
+func TestRateMatch(t *testing.T) {
+	cfg := DefaultBoardConfig()
+	ratings := map[string]int64{"fav": 1900, "dog": 1500}
+
+	deltas, err := rateMatch(cfg, Match{TeamA: []string{"dog"}, TeamB: []string{"fav"}, Winner: WinnerA}, ratings)
+	if err != nil {
+		t.Fatalf("rateMatch failed: %v", err)
+	}
+	if deltas[0].Delta != 29 || deltas[1].Delta != -29 {
+		t.Errorf("upset deltas = %+v; want +29 and -29", deltas)
+	}
+
+	// Teams are rated as the mean of their members
+	ratings = map[string]int64{"a1": 1700, "a2": 1300, "b1": 1500}
+	deltas, _ = rateMatch(cfg, Match{TeamA: []string{"a1", "a2"}, TeamB: []string{"b1"}, Winner: Draw}, ratings)
+	for _, d := range deltas {
+		if d.Delta != 0 {
+			t.Errorf("%s delta = %d; want 0 for a draw between even teams", d.Username, d.Delta)
+		}
+	}
+}
+
+func TestLeaderboard_RecordMatch(t *testing.T) {
+	lb := NewLeaderboard()
+	lb.AddUser("a1", 1500)
+	lb.AddUser("a2", 1500)
+	lb.AddUser("b1", 1500)
+	lb.AddUser("floor", MinRating)
+
+	res, err := lb.RecordMatch(Match{TeamA: []string{"a1", "a2"}, TeamB: []string{"b1"}, Winner: WinnerA})
+	if err != nil {
+		t.Fatalf("RecordMatch failed: %v", err)
+	}
+	if len(res.Players) != 3 {
+		t.Fatalf("got %d deltas; want 3", len(res.Players))
+	}
+	for _, d := range res.Players {
+		want := int64(16)
+		if d.Team == WinnerB {
+			want = -16
+		}
+		if d.Delta != want {
+			t.Errorf("%s delta = %d; want %d", d.Username, d.Delta, want)
+		}
+		u, _ := lb.GetUserRank(d.Username)
+		if u.Rating != d.NewRating {
+			t.Errorf("%s stored rating = %d; want %d", d.Username, u.Rating, d.NewRating)
+		}
+	}
+
+	// Losses at the floor are clamped
+	res, err = lb.RecordMatch(Match{TeamA: []string{"b1"}, TeamB: []string{"floor"}, Winner: WinnerA})
+	if err != nil {
+		t.Fatalf("RecordMatch failed: %v", err)
+	}
+	if d := res.Players[1]; d.NewRating != MinRating || d.Delta != 0 {
+		t.Errorf("floor delta = %+v; want clamped to %d", d, MinRating)
+	}
+
+	if _, err := lb.RecordMatch(Match{TeamA: []string{"a1"}, TeamB: []string{"ghost"}, Winner: Draw}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown player err = %v; want ErrUserNotFound", err)
+	}
+	if _, err := lb.RecordMatch(Match{TeamA: []string{"a1"}, TeamB: []string{"a1"}, Winner: Draw}); !errors.Is(err, ErrInvalidMatch) {
+		t.Errorf("duplicate player err = %v; want ErrInvalidMatch", err)
+	}
+}
+
+func TestGlicko2_Rate(t *testing.T) {
+	// Worked example from Glickman's Glicko-2 paper
+	p := PlayerRating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	got := Glicko2{Tau: 0.5}.Rate(p, []Outcome{
+		{Opponent: PlayerRating{Rating: 1400, Deviation: 30}, Score: 1},
+		{Opponent: PlayerRating{Rating: 1550, Deviation: 100}, Score: 0},
+		{Opponent: PlayerRating{Rating: 1700, Deviation: 300}, Score: 0},
+	})
+	if math.Abs(got.Rating-1464.06) > 0.01 {
+		t.Errorf("rating = %.4f; want 1464.06", got.Rating)
+	}
+	if math.Abs(got.Deviation-151.52) > 0.01 {
+		t.Errorf("deviation = %.4f; want 151.52", got.Deviation)
+	}
+	if math.Abs(got.Volatility-0.05999) > 0.00001 {
+		t.Errorf("volatility = %.6f; want 0.05999", got.Volatility)
+	}
+
+	// Sitting a period out only grows the deviation
+	idle := Glicko2{Tau: 0.5}.Rate(p, nil)
+	if idle.Rating != p.Rating || idle.Deviation <= p.Deviation {
+		t.Errorf("idle = %+v; want same rating and a larger deviation", idle)
+	}
+}
+
+func TestRatePeriod(t *testing.T) {
+	cfg := DefaultBoardConfig()
+	cfg.RatingSystem = RatingSystemGlicko2
+	players := map[string]PlayerRating{
+		"a": {Rating: 1500, Deviation: 350, Volatility: DefaultVolatility},
+		"b": {Rating: 1500, Deviation: 350, Volatility: DefaultVolatility},
+		"c": {Rating: 1500, Deviation: 350, Volatility: DefaultVolatility},
+	}
+	rated := ratePeriod(cfg, []Match{
+		{TeamA: []string{"a"}, TeamB: []string{"b"}, Winner: WinnerA},
+		{TeamA: []string{"a"}, TeamB: []string{"ghost"}, Winner: WinnerA}, // opponent left the board
+	}, players)
+
+	if len(rated) != 2 || rated[0].Username != "a" || rated[1].Username != "b" {
+		t.Fatalf("rated = %+v; want a and b", rated)
+	}
+	if rated[0].Games != 1 || rated[0].NewRating <= 1500 || rated[1].NewRating >= 1500 {
+		t.Errorf("rated = %+v; want one game, a up and b down", rated)
+	}
+	if rated[0].NewRating-1500 != 1500-rated[1].NewRating {
+		t.Errorf("even match moved a by %d and b by %d; want symmetric", rated[0].NewRating-1500, 1500-rated[1].NewRating)
+	}
+	if rated[0].Deviation >= 350 {
+		t.Errorf("deviation = %.2f; want it to shrink after a game", rated[0].Deviation)
+	}
+}
+
+func TestLeaderboard_DeleteUser(t *testing.T) {
+	lb := NewLeaderboard()
+	lb.AddUser("alice", 2000)
+	lb.AddUser("bob", 1500)
+
+	if err := lb.DeleteUser("alice"); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if err := lb.DeleteUser("alice"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("second delete err = %v; want ErrUserNotFound", err)
+	}
+	if lb.Count() != 1 {
+		t.Errorf("Count() = %d; want 1", lb.Count())
+	}
+	if u, _ := lb.GetUserRank("bob"); u.Rank != 1 {
+		t.Errorf("bob rank = %d; want 1", u.Rank)
+	}
+	if err := lb.AddUser("alice", 1000); err != nil {
+		t.Errorf("re-adding deleted user failed: %v", err)
+	}
+}
+
+// testIncrementConcurrently fires increments at one user from many
+// goroutines and checks that none of them were lost
+func testIncrementConcurrently(t *testing.T, lb Store) {
+	const workers, perWorker = 20, 25
+	if err := lb.AddUser("racer", 1000); err != nil {
+		t.Fatalf("AddUser failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(up bool) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				delta := int64(3)
+				if !up {
+					delta = -1
+				}
+				if _, err := lb.IncrementRating("racer", delta, SourceAPI); err != nil {
+					t.Errorf("IncrementRating failed: %v", err)
+					return
+				}
+			}
+		}(w%2 == 0)
+	}
+	wg.Wait()
+
+	want := int64(1000 + (workers/2)*perWorker*3 - (workers/2)*perWorker)
+	u, err := lb.GetUserRank("racer")
+	if err != nil {
+		t.Fatalf("GetUserRank failed: %v", err)
+	}
+	if u.Rating != want {
+		t.Errorf("rating after concurrent increments = %d; want %d", u.Rating, want)
+	}
+
+	// Increments saturate at the bounds instead of failing
+	cfg := lb.Config()
+	if u, err := lb.IncrementRating("racer", cfg.MaxRating*10, SourceAPI); err != nil || u.Rating != cfg.MaxRating || u.Rank != 1 {
+		t.Errorf("huge increment = %+v, %v; want %d at rank 1", u, err, cfg.MaxRating)
+	}
+	if u, _ := lb.IncrementRating("racer", -cfg.MaxRating*10, SourceAPI); u.Rating != cfg.MinRating {
+		t.Errorf("huge decrement rating = %d; want %d", u.Rating, cfg.MinRating)
+	}
+	if _, err := lb.IncrementRating("ghost", 1, SourceAPI); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user err = %v; want ErrUserNotFound", err)
+	}
+}
+
+func TestLeaderboard_IncrementRatingConcurrent(t *testing.T) {
+	testIncrementConcurrently(t, NewLeaderboard())
+}
+
+// testPostgresStore opens a throwaway board on the database named by
+// TEST_DATABASE_URL, skipping the test when it is not set
+func testPostgresStore(t *testing.T, cfg BoardConfig) *PostgresStore {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	boards, err := NewPostgresBoards(dsn)
+	if err != nil {
+		t.Fatalf("NewPostgresBoards failed: %v", err)
+	}
+	t.Cleanup(func() { boards.Close() })
+
+	cfg.Name = fmt.Sprintf("test-%d", time.Now().UnixNano())
+	if cfg.MinRating == 0 && cfg.MaxRating == 0 {
+		cfg.MinRating, cfg.MaxRating = MinRating, MaxRating
+	}
+	if _, err := boards.CreateBoard(cfg); err != nil {
+		t.Fatalf("CreateBoard failed: %v", err)
+	}
+	t.Cleanup(func() { boards.DeleteBoard(cfg.Name) })
+
+	lb, err := boards.Board(cfg.Name)
+	if err != nil {
+		t.Fatalf("Board failed: %v", err)
+	}
+	return lb.(*PostgresStore)
+}
+
+// TestPostgresStore_IncrementRatingConcurrent runs against a real database
+// when TEST_DATABASE_URL is set, on a throwaway board
+func TestPostgresStore_IncrementRatingConcurrent(t *testing.T) {
+	testIncrementConcurrently(t, testPostgresStore(t, BoardConfig{}))
+}
+
+func TestDiffTopN(t *testing.T) {
+	ranked := func(users ...User) []RankedUser {
+		out := make([]RankedUser, len(users))
+		for i, u := range users {
+			out[i] = RankedUser{User: u, Rank: i + 1}
+		}
+		return out
+	}
+	prev := ranked(User{Username: "alice", Rating: 3000}, User{Username: "bob", Rating: 2000}, User{Username: "carol", Rating: 1000})
+	cur := ranked(User{Username: "bob", Rating: 3500}, User{Username: "alice", Rating: 3000}, User{Username: "dave", Rating: 1200})
+
+	d := DiffTopN(prev, cur)
+	names := func(changes []RankChange) []string {
+		var out []string
+		for _, c := range changes {
+			out = append(out, c.Username)
+		}
+		return out
+	}
+	if got := fmt.Sprint(names(d.Entered)); got != "[dave]" {
+		t.Errorf("Entered = %v; want [dave]", got)
+	}
+	if got := fmt.Sprint(names(d.Left)); got != "[carol]" {
+		t.Errorf("Left = %v; want [carol]", got)
+	}
+	if got := fmt.Sprint(names(d.Moved)); got != "[bob alice]" {
+		t.Errorf("Moved = %v; want [bob alice]", got)
+	}
+	if got := fmt.Sprint(names(d.RatingChanged)); got != "[bob]" {
+		t.Errorf("RatingChanged = %v; want [bob]", got)
+	}
+	if c := d.RatingChanged[0]; *c.OldRating != 2000 || *c.OldRank != 2 || c.Rank != 1 {
+		t.Errorf("bob change = %+v; want 2000 at rank 2 -> rank 1", c)
+	}
+	if !DiffTopN(cur, cur).Empty() {
+		t.Error("diff of identical snapshots is not empty")
+	}
+}
+
+func TestFeed_PublishedByWrites(t *testing.T) {
+	lb := NewLeaderboard()
+	sub := lb.Changes().Subscribe(1)
+	defer sub.Close()
+
+	lb.AddUser("alice", 1500)
+	c := <-sub.C()
+	if c.Username != "alice" || c.OldRating != nil || *c.NewRating != 1500 || c.Board != DefaultBoard {
+		t.Errorf("AddUser change = %+v", c)
+	}
+
+	// A full buffer drops the change and marks the subscriber lagged
+	lb.IncrementRating("alice", 10, SourceAPI)
+	lb.DeleteUser("alice")
+	if c := <-sub.C(); *c.OldRating != 1500 || *c.NewRating != 1510 {
+		t.Errorf("IncrementRating change = %+v", c)
+	}
+	if !sub.Lagged() {
+		t.Error("subscriber not marked lagged after overflow")
+	}
+	if sub.Lagged() {
+		t.Error("Lagged did not reset")
+	}
+
+	lb.Seed(SeedOptions{Count: 10})
+	if c := <-sub.C(); !c.Bulk() || c.Source != SourceSeed {
+		t.Errorf("Seed change = %+v; want one bulk seed change", c)
+	}
+}
+
+func TestTxSnapshot_Visible(t *testing.T) {
+	// xmin is the oldest transaction still running, so it is listed in xip
+	snap, err := parseTxSnapshot("100:105:100,103")
+	if err != nil {
+		t.Fatalf("parseTxSnapshot failed: %v", err)
+	}
+	tests := []struct {
+		xid  int64
+		want bool
+	}{
+		{99, true},   // finished before the snapshot
+		{100, false}, // in progress
+		{101, true},  // committed while xmin was still running
+		{103, false},
+		{104, true},
+		{105, false}, // started after the snapshot
+	}
+	for _, tt := range tests {
+		if got := snap.visible(tt.xid); got != tt.want {
+			t.Errorf("visible(%d) = %v; want %v", tt.xid, got, tt.want)
+		}
+	}
+
+	if empty, err := parseTxSnapshot("7:7:"); err != nil || !empty.visible(6) || empty.visible(7) {
+		t.Errorf("snapshot with no running transactions = %+v, %v", empty, err)
+	}
+	if (txSnapshot{}).visible(1) {
+		t.Error("zero snapshot sees transactions")
+	}
+	if _, err := parseTxSnapshot("garbage"); err == nil {
+		t.Error("parseTxSnapshot accepted garbage")
+	}
+}
+
+func TestLeaderboard_GetAround(t *testing.T) {
+	lb := NewLeaderboard()
+	// Leaderboard order: alice, bob, carol, dave, erin (tied with dave), frank
+	lb.AddUser("alice", 3000)
+	lb.AddUser("bob", 2500)
+	lb.AddUser("carol", 2000)
+	lb.AddUser("erin", 1500)
+	lb.AddUser("dave", 1500)
+	lb.AddUser("frank", 1000)
+
+	summary := func(users []RankedUser) string {
+		var parts []string
+		for _, u := range users {
+			parts = append(parts, fmt.Sprintf("%s#%d", u.Username, u.Rank))
+		}
+		return fmt.Sprint(parts)
+	}
+
+	tests := []struct {
+		username string
+		radius   int
+		want     string
+	}{
+		{"carol", 1, "[bob#2 carol#3 dave#4]"},
+		{"erin", 1, "[dave#4 erin#4 frank#6]"}, // ties share a rank but keep their order
+		{"dave", 1, "[carol#3 dave#4 erin#4]"},
+		{"alice", 2, "[alice#1 bob#2 carol#3]"}, // clipped at the top
+		{"frank", 2, "[dave#4 erin#4 frank#6]"}, // and at the bottom
+	}
+	for _, tt := range tests {
+		users, err := lb.GetAround(tt.username, tt.radius)
+		if err != nil {
+			t.Fatalf("GetAround(%s) failed: %v", tt.username, err)
+		}
+		if got := summary(users); got != tt.want {
+			t.Errorf("GetAround(%s, %d) = %s; want %s", tt.username, tt.radius, got, tt.want)
+		}
+	}
+
+	if _, err := lb.GetAround("ghost", 1); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user err = %v; want ErrUserNotFound", err)
+	}
+}
+
+func TestLeaderboard_GetPageWalksWholeBoard(t *testing.T) {
+	lb := NewLeaderboard()
+	rng := rand.New(rand.NewSource(7))
+	for i := 0; i < 103; i++ {
+		// Narrow rating range so pages split tie groups
+		lb.AddUser(fmt.Sprintf("user_%03d", i), MinRating+rng.Int63n(20))
+	}
+	all := lb.GetTopN(1000, 0)
+
+	// Forward by cursor matches the full ranking, ranks included
+	var walked []RankedUser
+	cursor := Cursor{}
+	for pages := 0; ; pages++ {
+		if pages > 20 {
+			t.Fatal("pagination did not terminate")
+		}
+		page, err := lb.GetPage(cursor, 10)
+		if err != nil {
+			t.Fatalf("GetPage failed: %v", err)
+		}
+		if pages > 0 && page.PrevCursor() == nil {
+			t.Errorf("page %d has no prev cursor", pages)
+		}
+		walked = append(walked, page.Users...)
+		next := page.NextCursor()
+		if next == nil {
+			break
+		}
+		// Cursors survive the round trip through their token
+		decoded, err := DecodeCursor(next.Encode())
+		if err != nil || decoded != *next {
+			t.Fatalf("DecodeCursor(Encode(%+v)) = %+v, %v", *next, decoded, err)
+		}
+		cursor = decoded
+	}
+	if fmt.Sprint(walked) != fmt.Sprint(all) {
+		t.Errorf("forward walk differs from GetTopN:\n got %v\nwant %v", walked, all)
+	}
+
+	// And backward from the last page
+	var back []RankedUser
+	last, _ := lb.GetPage(Cursor{Rating: all[99].Rating, Username: all[99].Username}, 10)
+	back = append(back, last.Users...)
+	for prev := last.PrevCursor(); prev != nil; {
+		page, err := lb.GetPage(*prev, 10)
+		if err != nil {
+			t.Fatalf("GetPage failed: %v", err)
+		}
+		back = append(append([]RankedUser{}, page.Users...), back...)
+		prev = page.PrevCursor()
+	}
+	if fmt.Sprint(back) != fmt.Sprint(all) {
+		t.Errorf("backward walk differs from GetTopN:\n got %v\nwant %v", back, all)
+	}
+
+	if _, err := DecodeCursor("not a cursor"); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("DecodeCursor(garbage) err = %v; want ErrInvalidCursor", err)
+	}
+}
+
+func TestRankAmong(t *testing.T) {
+	got := rankAmong([]RankedUser{
+		{User: User{Username: "carol", Rating: 1500}, Rank: 40},
+		{User: User{Username: "alice", Rating: 1800}, Rank: 3},
+		{User: User{Username: "dave", Rating: 1200}, Rank: 90},
+		{User: User{Username: "bob", Rating: 1500}, Rank: 40},
+	}, DefaultBoardConfig())
+
+	want := []struct {
+		username   string
+		friendRank int
+		rank       int
+	}{
+		{"alice", 1, 3},
+		{"bob", 2, 40},
+		{"carol", 2, 40},
+		{"dave", 4, 90},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("rankAmong returned %d users; want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Username != w.username || g.FriendRank != w.friendRank || g.Rank != w.rank {
+			t.Errorf("position %d = %s friend rank %d rank %d; want %s friend rank %d rank %d",
+				i, g.Username, g.FriendRank, g.Rank, w.username, w.friendRank, w.rank)
+		}
+	}
+}
+
+func TestGroupAggregate(t *testing.T) {
+	tests := []struct {
+		in    GroupAggregate
+		want  GroupAggregate
+		valid bool
+	}{
+		{GroupAggregate{}, GroupAggregate{Mode: AggregateAvg}, true},
+		{GroupAggregate{Mode: AggregateSum}, GroupAggregate{Mode: AggregateSum}, true},
+		{GroupAggregate{Mode: AggregateTopK}, GroupAggregate{Mode: AggregateTopK, K: DefaultTopK}, true},
+		{GroupAggregate{Mode: AggregateTopK, K: 3}, GroupAggregate{Mode: AggregateTopK, K: 3}, true},
+		{GroupAggregate{Mode: AggregateTopK, K: -1}, GroupAggregate{Mode: AggregateTopK, K: -1}, false},
+		{GroupAggregate{Mode: AggregateAvg, K: 3}, GroupAggregate{Mode: AggregateAvg, K: 3}, false},
+		{GroupAggregate{Mode: AggregateSum, K: -1}, GroupAggregate{Mode: AggregateSum, K: -1}, false},
+		{GroupAggregate{Mode: "median"}, GroupAggregate{Mode: "median"}, false},
+	}
+	for _, tt := range tests {
+		got := tt.in.WithDefaults()
+		if got != tt.want {
+			t.Errorf("%+v.WithDefaults() = %+v; want %+v", tt.in, got, tt.want)
+		}
+		if err := got.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v.Validate() = %v; want valid %v", got, err, tt.valid)
+		} else if err != nil && !errors.Is(err, ErrInvalidGroup) {
+			t.Errorf("%+v.Validate() = %v; want ErrInvalidGroup", got, err)
+		}
+	}
+
+	if err := validateGroupName("Night Owls"); err != nil {
+		t.Errorf("validateGroupName(Night Owls) = %v", err)
+	}
+	if err := validateGroupName(" spaced"); !errors.Is(err, ErrInvalidGroup) {
+		t.Errorf("validateGroupName(\" spaced\") = %v; want ErrInvalidGroup", err)
+	}
+}
+
+func TestCalendarWindow(t *testing.T) {
+	// Late Wednesday in UTC, given in a zone where it is already Thursday
+	at := time.Date(2024, time.February, 29, 1, 30, 0, 0, time.FixedZone("UTC+3", 3*3600))
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		kind       string
+		start, end time.Time
+		prevStart  time.Time
+	}{
+		{WindowDaily, day(2024, 2, 28), day(2024, 2, 29), day(2024, 2, 27)},
+		{WindowWeekly, day(2024, 2, 26), day(2024, 3, 4), day(2024, 2, 19)},
+		{WindowMonthly, day(2024, 2, 1), day(2024, 3, 1), day(2024, 1, 1)},
+	}
+	for _, tt := range tests {
+		w, err := CalendarWindow(tt.kind, at)
+		if err != nil {
+			t.Fatalf("CalendarWindow(%s) failed: %v", tt.kind, err)
+		}
+		if !w.Start.Equal(tt.start) || !w.End.Equal(tt.end) {
+			t.Errorf("CalendarWindow(%s) = [%v, %v); want [%v, %v)", tt.kind, w.Start, w.End, tt.start, tt.end)
+		}
+		if prev := w.Previous(); !prev.Start.Equal(tt.prevStart) || !prev.End.Equal(w.Start) {
+			t.Errorf("%s Previous() = [%v, %v); want [%v, %v)", tt.kind, prev.Start, prev.End, tt.prevStart, w.Start)
+		}
+		if w.Closed(at) || !w.Closed(w.End) {
+			t.Errorf("%s Closed wrong around its end", tt.kind)
+		}
+
+		oldest := oldestRetained(tt.kind, at)
+		if !oldest.retained(at) || oldest.Previous().retained(at) {
+			t.Errorf("%s retention boundary wrong at %v", tt.kind, oldest.Start)
+		}
+	}
+
+	w, err := RollingWindow(WindowWeekly, at)
+	if err != nil || !w.Rolling || w.End.Sub(w.Start) != 7*24*time.Hour {
+		t.Errorf("RollingWindow(weekly) = %+v, %v", w, err)
+	}
+	if _, err := CalendarWindow("hourly", at); !errors.Is(err, ErrInvalidWindow) {
+		t.Errorf("CalendarWindow(hourly) err = %v; want ErrInvalidWindow", err)
+	}
+}
+
+func TestLeaderboard_RankingModes(t *testing.T) {
+	ranks := func(lb *Leaderboard) map[string]int {
+		out := make(map[string]int)
+		for _, u := range lb.GetTopN(10, 0) {
+			out[u.Username] = u.Rank
+		}
+		return out
+	}
+
+	tests := []struct {
+		mode string
+		want map[string]int
+	}{
+		{RankCompetition, map[string]int{"alice": 1, "bob": 2, "carol": 2, "dave": 4}},
+		{RankDense, map[string]int{"alice": 1, "bob": 2, "carol": 2, "dave": 3}},
+		{RankOrdinal, map[string]int{"alice": 1, "bob": 2, "carol": 3, "dave": 4}},
+	}
+	for _, tt := range tests {
+		cfg := DefaultBoardConfig()
+		cfg.RankingMode = tt.mode
+		lb := NewLeaderboardFor(cfg)
+		lb.AddUser("alice", 2000)
+		lb.AddUser("carol", 1500)
+		lb.AddUser("bob", 1500)
+		lb.AddUser("dave", 1000)
+
+		got := ranks(lb)
+		for name, want := range tt.want {
+			if got[name] != want {
+				t.Errorf("%s: GetTopN rank of %s = %d; want %d", tt.mode, name, got[name], want)
+			}
+			if u, _ := lb.GetUserRank(name); u.Rank != want {
+				t.Errorf("%s: GetUserRank(%s) = %d; want %d", tt.mode, name, u.Rank, want)
+			}
+		}
+		if found := lb.SearchUsers("car", 1); len(found) != 1 || found[0].Rank != tt.want["carol"] {
+			t.Errorf("%s: SearchUsers(car) = %+v", tt.mode, found)
+		}
+	}
+
+	cfg := DefaultBoardConfig()
+	cfg.RankingMode = RankDense
+	lb := NewLeaderboardFor(cfg)
+	lb.AddUser("alice", 2000)
+	lb.AddUser("bob", 1500)
+	lb.AddUser("carol", 1500)
+	lb.AddUser("dave", 1000)
+	if rating, err := lb.RatingAtRank(3); err != nil || rating != 1000 {
+		t.Errorf("dense RatingAtRank(3) = %d, %v; want 1000", rating, err)
+	}
+	if _, err := lb.RatingAtRank(4); !errors.Is(err, ErrRankOutOfRange) {
+		t.Errorf("dense RatingAtRank(4) err = %v; want ErrRankOutOfRange", err)
+	}
+}
+
+func TestLeaderboard_TieBreaks(t *testing.T) {
+	order := func(lb *Leaderboard) []string {
+		var names []string
+		for _, u := range lb.GetTopN(10, 0) {
+			names = append(names, u.Username)
+		}
+		return names
+	}
+	// Tie keys are wall-clock times, so space the writes out
+	step := func() { time.Sleep(time.Millisecond) }
+
+	for _, tieBreak := range []string{TieBreakUsername, TieBreakCreated, TieBreakAchieved} {
+		cfg := DefaultBoardConfig()
+		cfg.RankingMode = RankOrdinal
+		cfg.TieBreak = tieBreak
+		lb := NewLeaderboardFor(cfg)
+		lb.AddUser("zed", 1500)
+		step()
+		lb.AddUser("amy", 1400)
+		step()
+		lb.AddUser("kim", 1500)
+		step()
+		// amy reaches 1500 last, zed leaves and returns after her
+		lb.UpdateRating("amy", 1500)
+		step()
+		lb.UpdateRating("zed", 1600)
+		step()
+		lb.UpdateRating("zed", 1500)
+
+		want := map[string][]string{
+			TieBreakUsername: {"amy", "kim", "zed"},
+			TieBreakCreated:  {"zed", "amy", "kim"},
+			TieBreakAchieved: {"kim", "amy", "zed"},
+		}[tieBreak]
+		if got := order(lb); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: order = %v; want %v", tieBreak, got, want)
+		}
+		for i, name := range want {
+			if u, _ := lb.GetUserRank(name); u.Rank != i+1 {
+				t.Errorf("%s: GetUserRank(%s) = %d; want %d", tieBreak, name, u.Rank, i+1)
+			}
+		}
+
+		// Cursor pages must follow the same order across the tie
+		first, _ := lb.GetPage(Cursor{}, 2)
+		rest, _ := lb.GetPage(*first.NextCursor(), 2)
+		if len(rest.Users) != 1 || rest.Users[0].Username != want[2] {
+			t.Errorf("%s: second page = %+v; want %s", tieBreak, rest.Users, want[2])
+		}
+	}
+}
+
+func TestScoreTree(t *testing.T) {
+	for _, ascending := range []bool{false, true} {
+		tree := NewScoreTree(ascending)
+		counts := make(map[int64]int)
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 2000; i++ {
+			score := r.Int63n(50) * 1_000_000_007
+			if counts[score] > 0 && r.Intn(3) == 0 {
+				tree.Add(score, -1)
+				counts[score]--
+			} else {
+				tree.Add(score, 1)
+				counts[score]++
+			}
+		}
+
+		// Brute-force the same answers from the counts
+		ahead := func(a, b int64) bool { return (a < b) == ascending && a != b }
+		var order []int64
+		total, distinct := 0, 0
+		for score, n := range counts {
+			if n > 0 {
+				order = append(order, score)
+				total += n
+				distinct++
+			}
+		}
+		for i := range order {
+			for j := i + 1; j < len(order); j++ {
+				if ahead(order[j], order[i]) {
+					order[i], order[j] = order[j], order[i]
+				}
+			}
+		}
+
+		if tree.Len() != total || tree.Distinct() != distinct {
+			t.Fatalf("ascending=%v: Len, Distinct = %d, %d; want %d, %d", ascending, tree.Len(), tree.Distinct(), total, distinct)
+		}
+		k := 1
+		for i, score := range order {
+			wantAhead := k - 1
+			if got := tree.Ahead(score); got != wantAhead {
+				t.Errorf("ascending=%v: Ahead(%d) = %d; want %d", ascending, score, got, wantAhead)
+			}
+			if got := tree.DistinctAhead(score); got != i {
+				t.Errorf("ascending=%v: DistinctAhead(%d) = %d; want %d", ascending, score, got, i)
+			}
+			if got, ok := tree.KthDistinct(i + 1); !ok || got != score {
+				t.Errorf("ascending=%v: KthDistinct(%d) = %d, %v; want %d", ascending, i+1, got, ok, score)
+			}
+			for end := k + counts[score]; k < end; k++ {
+				if got, ok := tree.Kth(k); !ok || got != score {
+					t.Errorf("ascending=%v: Kth(%d) = %d, %v; want %d", ascending, k, got, ok, score)
+				}
+			}
+		}
+		if _, ok := tree.Kth(total + 1); ok {
+			t.Errorf("ascending=%v: Kth past the end succeeded", ascending)
+		}
+	}
+}
+
+func TestLeaderboard_AscendingScores(t *testing.T) {
+	cfg := DefaultBoardConfig()
+	cfg.Name = "speedrun"
+	cfg.Order = OrderAscending
+	cfg.MinRating, cfg.MaxRating = 0, 10_000_000_000
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	lb := NewLeaderboardFor(cfg)
+	lb.AddUser("slow", 9_000_000_000)
+	lb.AddUser("fast", 61_250)
+	lb.AddUser("mid", 4_000_000_000)
+	lb.AddUser("also_mid", 4_000_000_000)
+
+	top := lb.GetTopN(10, 0)
+	want := []struct {
+		username string
+		rank     int
+	}{{"fast", 1}, {"also_mid", 2}, {"mid", 2}, {"slow", 4}}
+	for i, w := range want {
+		if top[i].Username != w.username || top[i].Rank != w.rank {
+			t.Errorf("GetTopN[%d] = %s #%d; want %s #%d", i, top[i].Username, top[i].Rank, w.username, w.rank)
+		}
+	}
+	if rating, err := lb.RatingAtRank(4); err != nil || rating != 9_000_000_000 {
+		t.Errorf("RatingAtRank(4) = %d, %v; want 9000000000", rating, err)
+	}
+
+	page, _ := lb.GetPage(Cursor{}, 2)
+	next, _ := lb.GetPage(*page.NextCursor(), 2)
+	if len(next.Users) != 2 || next.Users[0].Username != "mid" || next.Users[1].Username != "slow" {
+		t.Errorf("second page = %+v; want mid, slow", next.Users)
+	}
+
+	// Huge deltas saturate at the bounds instead of overflowing
+	if u, err := lb.IncrementRating("fast", math.MinInt64, SourceAPI); err != nil || u.Rating != 0 || u.Rank != 1 {
+		t.Errorf("IncrementRating(MinInt64) = %+v, %v; want 0 at #1", u, err)
+	}
+	if u, err := lb.IncrementRating("fast", math.MaxInt64, SourceAPI); err != nil || u.Rating != cfg.MaxRating || u.Rank != 4 {
+		t.Errorf("IncrementRating(MaxInt64) = %+v, %v; want %d at #4", u, err, cfg.MaxRating)
+	}
+
+	if _, err := lb.RecordMatch(Match{TeamA: []string{"mid"}, TeamB: []string{"slow"}, Winner: "a"}); !errors.Is(err, ErrInvalidMatch) {
+		t.Errorf("RecordMatch on ascending board err = %v; want ErrInvalidMatch", err)
+	}
+}
+
+func TestLeaderboard_Submit(t *testing.T) {
+	lb := NewLeaderboard()
+	lb.AddUser("rival", 1500)
+
+	tests := []struct {
+		policy  string
+		score   int64
+		want    int64
+		changed bool
+	}{
+		{SubmitBest, 1200, 1200, true}, // creates the entry
+		{SubmitBest, 1100, 1200, false},
+		{SubmitBest, 1600, 1600, true},
+		{SubmitFirst, 2000, 1600, false},
+		{SubmitLatest, 1000, 1000, true},
+		{SubmitSum, 250, 1250, true},
+		{SubmitSum, 10_000, MaxRating, true},
+		{SubmitLatest, MaxRating, MaxRating, false},
+	}
+	for i, tt := range tests {
+		res, err := lb.Submit("player", tt.score, tt.policy, SourceAPI)
+		if err != nil {
+			t.Fatalf("#%d Submit(%s, %d) failed: %v", i, tt.policy, tt.score, err)
+		}
+		if res.Rating != tt.want || res.Changed != tt.changed || (res.Previous == nil) != (i == 0) {
+			t.Errorf("#%d Submit(%s, %d) = %d changed=%v previous=%v; want %d changed=%v",
+				i, tt.policy, tt.score, res.Rating, res.Changed, res.Previous, tt.want, tt.changed)
+		}
+		if u, _ := lb.GetUserRank("player"); u.Rank != res.Rank {
+			t.Errorf("#%d Submit rank = %d; GetUserRank says %d", i, res.Rank, u.Rank)
+		}
+	}
+
+	if _, err := lb.Submit("player", 1000, "worst", SourceAPI); !errors.Is(err, ErrInvalidPolicy) {
+		t.Errorf("Submit(worst) err = %v; want ErrInvalidPolicy", err)
+	}
+	if _, err := lb.Submit("newcomer", MaxRating+1, SubmitSum, SourceAPI); !errors.Is(err, ErrInvalidRating) {
+		t.Errorf("first sum submission out of bounds err = %v; want ErrInvalidRating", err)
+	}
+
+	// Best means lowest on ascending boards
+	cfg := DefaultBoardConfig()
+	cfg.Order = OrderAscending
+	asc := NewLeaderboardFor(cfg)
+	asc.Submit("runner", 900, SubmitBest, SourceAPI)
+	if res, _ := asc.Submit("runner", 950, SubmitBest, SourceAPI); res.Changed || res.Rating != 900 {
+		t.Errorf("ascending best kept %d changed=%v; want 900 unchanged", res.Rating, res.Changed)
+	}
+}
+
+func TestLeaderboard_BulkWrite(t *testing.T) {
+	rating := func(r int64) *int64 { return &r }
+	lb := NewLeaderboard()
+	lb.AddUser("existing", 1500)
+
+	rows := []BulkRow{
+		{Username: "new", Rating: rating(1200)},
+		{Username: "existing", Rating: rating(1600)},
+		{Username: "existing", Rating: rating(1700)},
+		{Username: "too_high", Rating: rating(MaxRating + 1)},
+		{Username: "no_rating"},
+		{Username: "", Rating: rating(1000)},
+	}
+	results, err := lb.BulkWrite(rows, BulkUpsert)
+	if err != nil {
+		t.Fatalf("BulkWrite failed: %v", err)
+	}
+	want := []string{BulkCreated, BulkUpdated, BulkDuplicate, BulkInvalidRating, BulkInvalidRating, BulkInvalidUsername}
+	for i, w := range want {
+		if results[i].Index != i || results[i].Status != w {
+			t.Errorf("result %d = %+v; want status %s", i, results[i], w)
+		}
+	}
+	if u, _ := lb.GetUserRank("existing"); u.Rating != 1600 {
+		t.Errorf("existing rating = %d; want 1600", u.Rating)
+	}
+
+	results, _ = lb.BulkWrite([]BulkRow{
+		{Username: "new", Rating: rating(1300)},
+		{Username: "ghost", Rating: rating(1300)},
+	}, BulkUpdate)
+	if results[0].Status != BulkUpdated || results[1].Status != BulkNotFound {
+		t.Errorf("update results = %+v", results)
+	}
+	results, _ = lb.BulkWrite([]BulkRow{
+		{Username: "new", Rating: rating(1300)},
+		{Username: "ghost", Rating: rating(1300)},
+	}, BulkInsert)
+	if results[0].Status != BulkDuplicate || results[1].Status != BulkCreated {
+		t.Errorf("insert results = %+v", results)
+	}
+	if _, err := lb.BulkWrite(nil, "merge"); !errors.Is(err, ErrInvalidBulk) {
+		t.Errorf("BulkWrite(merge) err = %v; want ErrInvalidBulk", err)
+	}
+	if lb.Count() != 3 {
+		t.Errorf("Count = %d; want 3", lb.Count())
+	}
+}
+
+func TestLeaderboard_Export(t *testing.T) {
+	cfg := DefaultBoardConfig()
+	cfg.RankingMode = RankDense
+	lb := NewLeaderboardFor(cfg)
+	lb.AddUser("carol", 1500)
+	lb.AddUser("alice", 2000)
+	lb.AddUser("bob", 1500)
+	lb.AddUser("dave", 1000)
+
+	var got []string
+	err := lb.Export(func(u RankedUser) error {
+		got = append(got, fmt.Sprintf("%d:%s", u.Rank, u.Username))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if want := "[1:alice 2:bob 2:carol 3:dave]"; fmt.Sprint(got) != want {
+		t.Errorf("Export = %v; want %s", got, want)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = lb.Export(func(RankedUser) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Export after fn error = %v with %d calls; want stop after 1", err, calls)
+	}
+}
+
+func TestLeaderboard_Import(t *testing.T) {
+	rating := func(r int64) *int64 { return &r }
+	lb := NewLeaderboard()
+	lb.AddUser("existing", 1500)
+
+	rows := []BulkRow{
+		{Username: "new", Rating: rating(1200)},
+		{Username: "existing", Rating: rating(1600)},
+	}
+
+	result, err := Import(lb, rows, ConflictOverwrite, true)
+	if err != nil || result.Applied {
+		t.Fatalf("dry run = %+v, %v", result, err)
+	}
+	if result.Results[0].Status != BulkCreated || result.Results[1].Status != BulkUpdated {
+		t.Errorf("dry run results = %+v", result.Results)
+	}
+	if _, err := lb.GetUserRank("new"); err != ErrUserNotFound {
+		t.Errorf("dry run wrote new user: %v", err)
+	}
+
+	result, err = Import(lb, rows, ConflictFail, false)
+	if !errors.Is(err, ErrImportConflict) || result.Applied {
+		t.Fatalf("fail import = %+v, %v; want ErrImportConflict", result, err)
+	}
+	if _, err := lb.GetUserRank("new"); err != ErrUserNotFound {
+		t.Errorf("failed import wrote new user: %v", err)
+	}
+
+	result, err = Import(lb, rows, ConflictSkip, false)
+	if err != nil || !result.Applied {
+		t.Fatalf("skip import = %+v, %v", result, err)
+	}
+	if u, _ := lb.GetUserRank("existing"); u.Rating != 1500 {
+		t.Errorf("skip overwrote existing: rating %d", u.Rating)
+	}
+
+	rows[0].Rating = rating(1250)
+	if _, err := Import(lb, rows, ConflictOverwrite, false); err != nil {
+		t.Fatalf("overwrite import failed: %v", err)
+	}
+	if u, _ := lb.GetUserRank("existing"); u.Rating != 1600 {
+		t.Errorf("existing rating = %d; want 1600", u.Rating)
+	}
+	if u, _ := lb.GetUserRank("new"); u.Rating != 1250 {
+		t.Errorf("new rating = %d; want 1250", u.Rating)
+	}
+
+	if _, err := Import(lb, rows, "merge", false); !errors.Is(err, ErrInvalidBulk) {
+		t.Errorf("unknown conflict mode err = %v; want ErrInvalidBulk", err)
+	}
+}
+
+// checkImportFailIsAtomic imports rows spanning several bulk batches
+// whose last row exists, and checks that no batch was written
+func checkImportFailIsAtomic(t *testing.T, lb Store) {
+	t.Helper()
+	if err := lb.AddUser("existing", 1500); err != nil {
+		t.Fatalf("AddUser failed: %v", err)
+	}
+	var rows []BulkRow
+	for i := 0; i < BulkBatchSize+100; i++ {
+		rating := int64(1000 + i%500)
+		rows = append(rows, BulkRow{Username: fmt.Sprintf("import%04d", i), Rating: &rating})
+	}
+	rows[len(rows)-1].Username = "existing"
+
+	result, err := Import(lb, rows, ConflictFail, false)
+	if !errors.Is(err, ErrImportConflict) || result.Applied {
+		t.Fatalf("fail import = %v; want ErrImportConflict", err)
+	}
+	if last := result.Results[len(rows)-1]; last.Status != BulkDuplicate {
+		t.Errorf("last row = %+v; want duplicate", last)
+	}
+	if n := lb.Count(); n != 1 {
+		t.Errorf("Count after failed import = %d; want 1", n)
+	}
+	if _, err := lb.GetUserRank("import0000"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("failed import wrote the first batch: %v", err)
+	}
+}
+
+func TestLeaderboard_ImportFailIsAtomic(t *testing.T) {
+	checkImportFailIsAtomic(t, NewLeaderboard())
+}
+
+func TestPostgresStore_ImportFailIsAtomic(t *testing.T) {
+	checkImportFailIsAtomic(t, testPostgresStore(t, BoardConfig{}))
+}
+
+func TestLeaderboard_Seed(t *testing.T) {
+	snapshot := func(opts SeedOptions) (*SeedResult, []RankedUser) {
+		lb := NewLeaderboard()
+		result, err := lb.Seed(opts)
+		if err != nil {
+			t.Fatalf("Seed(%+v) failed: %v", opts, err)
+		}
+		return result, lb.GetTopN(lb.Count(), 0)
+	}
+
+	opts := SeedOptions{Count: 200, RandomSeed: 42}
+	first, users := snapshot(opts)
+	second, again := snapshot(opts)
+	if first.RandomSeed != 42 || first.Created != second.Created || len(users) != len(again) {
+		t.Fatalf("same seed gave %+v and %+v", first, second)
+	}
+	for i := range users {
+		if users[i].Username != again[i].Username || users[i].Rating != again[i].Rating {
+			t.Fatalf("user %d = %+v then %+v; want the same", i, users[i], again[i])
+		}
+	}
+	if first.Created+first.Skipped != 200 || first.Created != len(users) {
+		t.Errorf("result %+v does not match %d users", first, len(users))
+	}
+
+	result, users := snapshot(SeedOptions{Count: 50, UsernamePattern: "load_{i}"})
+	if result.RandomSeed == 0 {
+		t.Error("seed picked from the clock not reported")
+	}
+	if result.Created != 50 || users[0].Username[:5] != "load_" {
+		t.Errorf("pattern seed = %+v, first user %+v", result, users[0])
+	}
+
+	// Rerunning a seed over its own users creates nothing
+	lb := NewLeaderboard()
+	lb.Seed(SeedOptions{Count: 20, RandomSeed: 7, UsernamePattern: "p{i}"})
+	result, _ = lb.Seed(SeedOptions{Count: 20, RandomSeed: 7, UsernamePattern: "p{i}"})
+	if result.Created != 0 || result.Skipped != 20 || lb.Count() != 20 {
+		t.Errorf("repeated seed = %+v with %d users", result, lb.Count())
+	}
+
+	mean := 1000.0
+	_, users = snapshot(SeedOptions{Count: 2000, RandomSeed: 1, Distribution: DistNormal, Mean: &mean, StdDev: 100, UsernamePattern: "n{i}"})
+	sum := int64(0)
+	for _, u := range users {
+		sum += u.Rating
+	}
+	if avg := sum / int64(len(users)); avg < 980 || avg > 1020 {
+		t.Errorf("normal mean = %d; want about 1000", avg)
+	}
+
+	_, users = snapshot(SeedOptions{Count: 2000, RandomSeed: 1, Distribution: DistPowerLaw, UsernamePattern: "p{i}"})
+	median := users[len(users)/2].Rating
+	if median > MinRating+(MaxRating-MinRating)/10 || users[0].Rating <= median {
+		t.Errorf("power law median %d, top %d; want a low median and a long tail", median, users[0].Rating)
+	}
+	for _, u := range users {
+		if u.Rating < MinRating || u.Rating > MaxRating {
+			t.Fatalf("rating %d out of bounds", u.Rating)
+		}
+	}
+
+	for _, bad := range []SeedOptions{
+		{Count: 1, Distribution: "zipf"},
+		{Count: 1, UsernamePattern: "fixed"},
+		{Count: 1, Distribution: DistNormal, StdDev: -1},
+	} {
+		if _, err := NewLeaderboard().Seed(bad); !errors.Is(err, ErrInvalidSeed) {
+			t.Errorf("Seed(%+v) err = %v; want ErrInvalidSeed", bad, err)
+		}
+	}
+}
+
+func TestPostgresStore_CloseEmptyRatingPeriod(t *testing.T) {
+	lb := testPostgresStore(t, BoardConfig{RatingSystem: RatingSystemGlicko2})
+	if err := lb.AddUser("idle", 1500); err != nil {
+		t.Fatalf("AddUser failed: %v", err)
+	}
+	if _, err := lb.db.Exec("UPDATE users SET deviation = 100 WHERE board = $1", lb.cfg.Name); err != nil {
+		t.Fatalf("setting deviation failed: %v", err)
+	}
+
+	period, err := lb.CloseRatingPeriod()
+	if err != nil {
+		t.Fatalf("CloseRatingPeriod failed: %v", err)
+	}
+	if period.Matches != 0 || len(period.Players) != 0 {
+		t.Errorf("empty period = %+v; want no matches or players", period)
+	}
+
+	var deviation float64
+	err = lb.db.QueryRow("SELECT deviation FROM users WHERE board = $1 AND username = 'idle'", lb.cfg.Name).Scan(&deviation)
+	if err != nil {
+		t.Fatalf("reading deviation failed: %v", err)
+	}
+	if deviation <= 100 {
+		t.Errorf("deviation after an idle period = %.2f; want above 100", deviation)
+	}
+}
+
+func TestLeaderboard_OrdinalRanksMatchPositions(t *testing.T) {
+	cfg := DefaultBoardConfig()
+	cfg.RankingMode = RankOrdinal
+	lb := NewLeaderboardFor(cfg)
+	for i := 0; i < 200; i++ {
+		lb.AddUser(fmt.Sprintf("user%03d", i), int64(1000+100*(i%5)))
+	}
+	lb.SetRating("user007", 1000, SourceAPI) // moves between tied groups
+	lb.DeleteUser("user010")
+
+	top := lb.GetTopN(lb.Count(), 0)
+	for i, u := range top {
+		if u.Rank != i+1 {
+			t.Fatalf("position %d has rank %d; want %d", i, u.Rank, i+1)
+		}
+		if got, _ := lb.GetUserRank(u.Username); got.Rank != u.Rank {
+			t.Errorf("GetUserRank(%s) = %d; GetTopN says %d", u.Username, got.Rank, u.Rank)
+		}
+	}
+}
+
+func TestPostgresStore_AscendingPeak(t *testing.T) {
+	lb := testPostgresStore(t, BoardConfig{Order: OrderAscending, MinRating: 0, MaxRating: 100_000})
+	if err := lb.AddUser("runner", 600); err != nil {
+		t.Fatalf("AddUser failed: %v", err)
+	}
+	for _, secs := range []int64{540, 575} {
+		if err := lb.SetRating("runner", secs, SourceAPI); err != nil {
+			t.Fatalf("SetRating(%d) failed: %v", secs, err)
+		}
+	}
+
+	h, err := lb.RatingHistory("runner", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("RatingHistory failed: %v", err)
+	}
+	if h.PeakRating != 540 {
+		t.Errorf("peak = %d; want the fastest time, 540", h.PeakRating)
 	}
 }
@@ -0,0 +1,265 @@
+package leaderboard
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var ErrInvalidMatch = errors.New("invalid match")
+
+// Match winners
+const (
+	WinnerA = "a"
+	WinnerB = "b"
+	Draw    = "draw"
+)
+
+// Match is the result of a game between two players or two teams
+type Match struct {
+	TeamA  []string `json:"team_a"`
+	TeamB  []string `json:"team_b"`
+	Winner string   `json:"winner"` // "a", "b" or "draw"
+}
+
+// Validate checks that both sides are non-empty, disjoint and the winner is known
+func (m Match) Validate() error {
+	if len(m.TeamA) == 0 || len(m.TeamB) == 0 {
+		return fmt.Errorf("%w: both teams need at least one player", ErrInvalidMatch)
+	}
+	seen := make(map[string]bool)
+	for _, name := range m.players() {
+		if name == "" {
+			return fmt.Errorf("%w: empty username", ErrInvalidMatch)
+		}
+		if seen[name] {
+			return fmt.Errorf("%w: %s appears more than once", ErrInvalidMatch, name)
+		}
+		seen[name] = true
+	}
+	if _, err := m.scoreA(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// players lists every username in the match, team A first
+func (m Match) players() []string {
+	return append(append([]string{}, m.TeamA...), m.TeamB...)
+}
+
+// scoreA is team A's game score: 1 for a win, 0.5 for a draw, 0 for a loss
+func (m Match) scoreA() (float64, error) {
+	switch m.Winner {
+	case WinnerA:
+		return 1, nil
+	case WinnerB:
+		return 0, nil
+	case Draw:
+		return 0.5, nil
+	default:
+		return 0, fmt.Errorf("%w: winner must be %q, %q or %q", ErrInvalidMatch, WinnerA, WinnerB, Draw)
+	}
+}
+
+// PlayerDelta is the rating change a match applied to one player
+type PlayerDelta struct {
+	Username  string `json:"username"`
+	Team      string `json:"team"`
+	OldRating int64  `json:"old_rating"`
+	NewRating int64  `json:"new_rating"`
+	Delta     int64  `json:"delta"` // after clamping to the board bounds
+}
+
+// MatchResult lists the rating changes applied by a match.
+// On boards with a batched rating system the match is only queued: Pending
+// is set and ratings change when the rating period closes.
+type MatchResult struct {
+	ID      int64         `json:"id,omitempty"` // set when the store keeps a match log
+	Pending bool          `json:"pending"`
+	Players []PlayerDelta `json:"players"`
+}
+
+// MatchStore is implemented by stores that can apply match results atomically
+type MatchStore interface {
+	RecordMatch(m Match) (*MatchResult, error)
+}
+
+var (
+	_ MatchStore = (*Leaderboard)(nil)
+	_ MatchStore = (*PostgresStore)(nil)
+)
+
+// rateMatch computes every player's new rating under an immediate rating
+// system. Teams are rated as the mean of their members and every member
+// gets the team's delta, clamped to the board bounds. ratings must hold
+// every player of the match.
+func rateMatch(cfg BoardConfig, m Match, ratings map[string]int64) ([]PlayerDelta, error) {
+	scoreA, err := m.scoreA()
+	if err != nil {
+		return nil, err
+	}
+
+	a := PlayerRating{Rating: teamRating(m.TeamA, ratings)}
+	b := PlayerRating{Rating: teamRating(m.TeamB, ratings)}
+	rated := cfg.ratingSystem().Rate(a, []Outcome{{Opponent: b, Score: scoreA}})
+	delta := int64(math.Round(rated.Rating - a.Rating))
+
+	var deltas []PlayerDelta
+	apply := func(team string, names []string, d int64) {
+		for _, name := range names {
+			old := ratings[name]
+			next := clamp(old+d, cfg.MinRating, cfg.MaxRating)
+			deltas = append(deltas, PlayerDelta{
+				Username:  name,
+				Team:      team,
+				OldRating: old,
+				NewRating: next,
+				Delta:     next - old,
+			})
+		}
+	}
+	apply(WinnerA, m.TeamA, delta)
+	apply(WinnerB, m.TeamB, -delta)
+	return deltas, nil
+}
+
+// ratePeriod rates a batch of matches under a batched rating system. Each
+// player's games are scored against a composite of the opposing team: the
+// mean rating and the root-mean-square deviation of its members. Players
+// who left the board since a match was queued are skipped, as are matches
+// with a whole side gone. Results are sorted by username.
+func ratePeriod(cfg BoardConfig, matches []Match, players map[string]PlayerRating) []RatedPlayer {
+	outcomes := make(map[string][]Outcome)
+	composite := func(names []string) (PlayerRating, []string) {
+		var present []string
+		var c PlayerRating
+		for _, name := range names {
+			if p, ok := players[name]; ok {
+				present = append(present, name)
+				c.Rating += p.Rating
+				c.Deviation += p.Deviation * p.Deviation
+			}
+		}
+		if len(present) > 0 {
+			c.Rating /= float64(len(present))
+			c.Deviation = math.Sqrt(c.Deviation / float64(len(present)))
+		}
+		return c, present
+	}
+
+	for _, m := range matches {
+		scoreA, err := m.scoreA()
+		if err != nil {
+			continue
+		}
+		a, teamA := composite(m.TeamA)
+		b, teamB := composite(m.TeamB)
+		if len(teamA) == 0 || len(teamB) == 0 {
+			continue
+		}
+		for _, name := range teamA {
+			outcomes[name] = append(outcomes[name], Outcome{Opponent: b, Score: scoreA})
+		}
+		for _, name := range teamB {
+			outcomes[name] = append(outcomes[name], Outcome{Opponent: a, Score: 1 - scoreA})
+		}
+	}
+
+	system := cfg.ratingSystem()
+	rated := make([]RatedPlayer, 0, len(outcomes))
+	for name, games := range outcomes {
+		p := players[name]
+		next := system.Rate(p, games)
+		rated = append(rated, RatedPlayer{
+			Username:   name,
+			Games:      len(games),
+			OldRating:  int64(p.Rating),
+			NewRating:  clamp(int64(math.Round(next.Rating)), cfg.MinRating, cfg.MaxRating),
+			Deviation:  next.Deviation,
+			Volatility: next.Volatility,
+		})
+	}
+	sort.Slice(rated, func(i, j int) bool { return rated[i].Username < rated[j].Username })
+	return rated
+}
+
+func teamRating(names []string, ratings map[string]int64) float64 {
+	sum := 0.0
+	for _, name := range names {
+		sum += float64(ratings[name])
+	}
+	return sum / float64(len(names))
+}
+
+func clamp(v, lo, hi int64) int64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// addClamped returns v+delta clamped to [lo, hi] for v within [lo, hi],
+// saturating instead of overflowing
+func addClamped(v, delta, lo, hi int64) int64 {
+	if delta > hi-v {
+		return hi
+	}
+	if delta < lo-v {
+		return lo
+	}
+	return v + delta
+}
+
+// missingPlayers returns the match players absent from ratings, sorted
+func missingPlayers(m Match, ratings map[string]int64) []string {
+	var missing []string
+	for _, name := range m.players() {
+		if _, ok := ratings[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// RecordMatch applies a match result under the board lock
+func (lb *Leaderboard) RecordMatch(m Match) (*MatchResult, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	if lb.cfg.ascending() {
+		// Rating systems raise winners, which would sink them on these boards
+		return nil, fmt.Errorf("%w: ascending boards do not rate matches", ErrInvalidMatch)
+	}
+	if lb.cfg.ratingSystem().Batched() {
+		return nil, fmt.Errorf("%s rating periods are %w by the in-memory engine", lb.cfg.RatingSystem, ErrUnsupported)
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	ratings := make(map[string]int64)
+	for _, name := range m.players() {
+		if rating, ok := lb.users[name]; ok {
+			ratings[name] = rating
+		}
+	}
+	if missing := missingPlayers(m, ratings); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %v", ErrUserNotFound, missing)
+	}
+
+	deltas, err := rateMatch(lb.cfg, m, ratings)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deltas {
+		lb.move(d.Username, d.NewRating)
+		lb.feed.publishUser(d.Username, &d.OldRating, &d.NewRating, SourceMatch)
+	}
+	return &MatchResult{Players: deltas}, nil
+}
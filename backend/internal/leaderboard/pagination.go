@@ -0,0 +1,130 @@
+package leaderboard
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a position in leaderboard order (rating desc, tie key asc,
+// username asc). A page fetched with it starts just after the position,
+// or ends just before it when Backward is set. The zero Cursor is the top
+// of the board.
+type Cursor struct {
+	Rating   int64
+	Tie      time.Time // tie-break key of the user at the position
+	Username string
+	Backward bool
+}
+
+// cursorJSON is the wire form of a Cursor; keys are short to keep URLs short
+type cursorJSON struct {
+	Rating   int64  `json:"r"`
+	Tie      int64  `json:"t,omitempty"` // unix nanoseconds
+	Username string `json:"u"`
+	Backward bool   `json:"b,omitempty"`
+}
+
+// cursorAt returns the cursor positioned at u
+func cursorAt(u User, backward bool) *Cursor {
+	return &Cursor{Rating: u.Rating, Tie: u.tie, Username: u.Username, Backward: backward}
+}
+
+// Encode returns the cursor as an opaque URL-safe token
+func (c Cursor) Encode() string {
+	var tie int64
+	if !c.Tie.IsZero() {
+		tie = c.Tie.UnixNano()
+	}
+	b, _ := json.Marshal(cursorJSON{Rating: c.Rating, Tie: tie, Username: c.Username, Backward: c.Backward})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a token produced by Cursor.Encode
+func DecodeCursor(token string) (Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var c cursorJSON
+	if err := json.Unmarshal(b, &c); err != nil || c.Username == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{Rating: c.Rating, Tie: time.Unix(0, c.Tie).UTC(), Username: c.Username, Backward: c.Backward}, nil
+}
+
+// Page is one page of the leaderboard fetched by cursor
+type Page struct {
+	Users   []RankedUser
+	HasPrev bool
+	HasNext bool
+}
+
+// NextCursor points just after the last user of the page, or is nil on
+// the last page
+func (p Page) NextCursor() *Cursor {
+	if !p.HasNext || len(p.Users) == 0 {
+		return nil
+	}
+	return cursorAt(p.Users[len(p.Users)-1].User, false)
+}
+
+// PrevCursor points just before the first user of the page, or is nil on
+// the first page
+func (p Page) PrevCursor() *Cursor {
+	if !p.HasPrev || len(p.Users) == 0 {
+		return nil
+	}
+	return cursorAt(p.Users[0].User, true)
+}
+
+// before reports whether u comes before the cursor position in the
+// board's leaderboard order
+func (c Cursor) before(cfg BoardConfig, u User) bool {
+	return cfg.listedBefore(u, User{Username: c.Username, Rating: c.Rating, tie: c.Tie})
+}
+
+// pageOf cuts a page out of users sorted in the board's leaderboard order
+func pageOf(cfg BoardConfig, sorted []User, cursor Cursor, limit int) (start, end int, page Page) {
+	// First position at or after the cursor
+	pos := 0
+	if cursor.Username != "" {
+		lo, hi := 0, len(sorted)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if cursor.before(cfg, sorted[mid]) {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		pos = lo
+	}
+
+	if cursor.Backward {
+		end = pos
+		start = end - limit
+		if start < 0 {
+			start = 0
+		}
+		page.HasPrev = start > 0
+		page.HasNext = true
+		return start, end, page
+	}
+
+	start = pos
+	// Skip the cursor's own user; it ended the previous page
+	if cursor.Username != "" && start < len(sorted) && sorted[start].Username == cursor.Username && sorted[start].Rating == cursor.Rating {
+		start++
+	}
+	end = start + limit
+	if end > len(sorted) {
+		end = len(sorted)
+	}
+	page.HasPrev = start > 0
+	page.HasNext = end < len(sorted)
+	return start, end, page
+}
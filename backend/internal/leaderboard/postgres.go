@@ -0,0 +1,1116 @@
+package leaderboard
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PostgresBoards owns the database connection and serves one PostgresStore
+// per named board. Every board's users live in the shared users table.
+type PostgresBoards struct {
+	db       *sql.DB
+	instance string // tags this process's change notices
+
+	mu     sync.RWMutex
+	boards map[string]*PostgresStore
+	done   chan struct{}
+}
+
+// NewPostgresBoards connects to Postgres, ensures schema exists and loads
+// the rank cache of every board
+func NewPostgresBoards(dsn string) (*PostgresBoards, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	// OPTIMIZATION: Tune Connection Pool
+	// SetMaxOpenConns: Max concurrent connections to the DB.
+	// 25 is a good starting point for Azure/Cloud Postgres small instances.
+	db.SetMaxOpenConns(25)
+	// SetMaxIdleConns: Keep these ready to avoid handshake latency.
+	db.SetMaxIdleConns(25)
+	// SetConnMaxLifetime: Recycle connections to prevent stale timeouts.
+	db.SetConnMaxLifetime(5 * time.Minute)
+
+	pb := &PostgresBoards{
+		db:       db,
+		instance: newInstanceID(),
+		boards:   make(map[string]*PostgresStore),
+		done:     make(chan struct{}),
+	}
+	if err := pb.initSchema(); err != nil {
+		return nil, err
+	}
+
+	configs, err := pb.queryBoards()
+	if err != nil {
+		return nil, err
+	}
+	for _, cfg := range configs {
+		if _, err := pb.open(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := pb.listen(dsn); err != nil {
+		return nil, err
+	}
+	return pb, nil
+}
+
+func (pb *PostgresBoards) initSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS boards (
+		name VARCHAR(64) PRIMARY KEY,
+		min_rating BIGINT NOT NULL,
+		max_rating BIGINT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		CHECK (min_rating < max_rating)
+	);
+	ALTER TABLE boards ADD COLUMN IF NOT EXISTS k_factor DOUBLE PRECISION NOT NULL DEFAULT 32;
+	ALTER TABLE boards ADD COLUMN IF NOT EXISTS rating_system VARCHAR(16) NOT NULL DEFAULT 'elo';
+	ALTER TABLE boards ADD COLUMN IF NOT EXISTS ranking_mode VARCHAR(16) NOT NULL DEFAULT 'competition';
+	ALTER TABLE boards ADD COLUMN IF NOT EXISTS tie_break VARCHAR(16) NOT NULL DEFAULT 'username';
+	ALTER TABLE boards ADD COLUMN IF NOT EXISTS sort_order VARCHAR(4) NOT NULL DEFAULT 'desc';
+	ALTER TABLE boards ADD COLUMN IF NOT EXISTS decimals SMALLINT NOT NULL DEFAULT 0;
+	INSERT INTO boards (name, min_rating, max_rating) VALUES ('global', 100, 5000)
+	ON CONFLICT DO NOTHING;
+
+	CREATE TABLE IF NOT EXISTS users (
+		board VARCHAR(64) NOT NULL DEFAULT 'global' REFERENCES boards(name) ON DELETE CASCADE,
+		username VARCHAR(255) NOT NULL,
+		rating BIGINT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (board, username)
+	);
+
+	-- Upgrade a pre-boards users table: existing rows join the global board,
+	-- the key becomes (board, username) and bounds move to the boards table
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS board VARCHAR(64) NOT NULL DEFAULT 'global'
+		REFERENCES boards(name) ON DELETE CASCADE;
+	DO $$
+	BEGIN
+		IF NOT EXISTS (
+			SELECT 1 FROM pg_constraint
+			WHERE conrelid = 'users'::regclass AND contype = 'p' AND array_length(conkey, 1) = 2
+		) THEN
+			ALTER TABLE users DROP CONSTRAINT IF EXISTS users_pkey;
+			ALTER TABLE users ADD PRIMARY KEY (board, username);
+		END IF;
+	END $$;
+	ALTER TABLE users DROP CONSTRAINT IF EXISTS users_rating_check;
+	DROP INDEX IF EXISTS idx_rating;
+	DROP INDEX IF EXISTS idx_username_lower;
+
+	-- tie_at orders equal ratings by the board's tie-break: when the rating
+	-- was reached, when the user joined, or the epoch to fall through to
+	-- the username. Every listing walks this index.
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS tie_at TIMESTAMPTZ NOT NULL DEFAULT 'epoch';
+	DROP INDEX IF EXISTS idx_board_rating;
+	CREATE INDEX IF NOT EXISTS idx_board_rating_tie ON users(board, rating DESC, tie_at, username);
+	-- Ascending boards list best first by rating ASC with the same tie order,
+	-- which the descending index cannot serve backwards
+	CREATE INDEX IF NOT EXISTS idx_board_rating_asc_tie ON users(board, rating, tie_at, username);
+	-- OPTIMIZATION: Index for fast case-insensitive prefix search
+	CREATE INDEX IF NOT EXISTS idx_board_username_lower ON users(board, lower(username) varchar_pattern_ops);
+
+	-- Closed seasons and their frozen final standings
+	CREATE TABLE IF NOT EXISTS seasons (
+		id BIGSERIAL PRIMARY KEY,
+		board VARCHAR(64) NOT NULL REFERENCES boards(name) ON DELETE CASCADE,
+		name VARCHAR(255) NOT NULL,
+		started_at TIMESTAMP NOT NULL,
+		ended_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		players INTEGER NOT NULL DEFAULT 0,
+		reset_mode VARCHAR(16) NOT NULL,
+		UNIQUE (board, name)
+	);
+	CREATE TABLE IF NOT EXISTS season_standings (
+		season_id BIGINT NOT NULL REFERENCES seasons(id) ON DELETE CASCADE,
+		username VARCHAR(255) NOT NULL,
+		rating BIGINT NOT NULL,
+		rank INTEGER NOT NULL,
+		PRIMARY KEY (season_id, username)
+	);
+	CREATE INDEX IF NOT EXISTS idx_season_standings_rank ON season_standings(season_id, rank, username);
+	CREATE INDEX IF NOT EXISTS idx_season_standings_user ON season_standings(username);
+
+	-- Every rating change, for history charts
+	CREATE TABLE IF NOT EXISTS rating_history (
+		id BIGSERIAL PRIMARY KEY,
+		board VARCHAR(64) NOT NULL REFERENCES boards(name) ON DELETE CASCADE,
+		username VARCHAR(255) NOT NULL,
+		old_rating BIGINT,
+		new_rating BIGINT NOT NULL,
+		rank INTEGER NOT NULL,
+		source VARCHAR(16) NOT NULL,
+		changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_rating_history_user ON rating_history(board, username, changed_at);
+
+	-- Match log
+	CREATE TABLE IF NOT EXISTS matches (
+		id BIGSERIAL PRIMARY KEY,
+		board VARCHAR(64) NOT NULL REFERENCES boards(name) ON DELETE CASCADE,
+		team_a TEXT[] NOT NULL,
+		team_b TEXT[] NOT NULL,
+		winner VARCHAR(8) NOT NULL,
+		played_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	-- Matches on batched (Glicko-2) boards wait here until their rating period closes
+	ALTER TABLE matches ADD COLUMN IF NOT EXISTS rated BOOLEAN NOT NULL DEFAULT TRUE;
+	CREATE INDEX IF NOT EXISTS idx_matches_pending ON matches(board, id) WHERE NOT rated;
+
+	-- Glicko-2 state; ignored by Elo boards
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS deviation DOUBLE PRECISION NOT NULL DEFAULT 350;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS volatility DOUBLE PRECISION NOT NULL DEFAULT 0.06;
+
+	-- Who follows whom; shared by every board
+	CREATE TABLE IF NOT EXISTS friends (
+		username VARCHAR(255) NOT NULL,
+		friend VARCHAR(255) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (username, friend),
+		CHECK (username <> friend)
+	);
+	-- Friends and groups check that a user plays on any board, which the
+	-- (board, username) key cannot serve
+	CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);
+
+	-- Groups (clans) and their members; a user is in at most one group
+	CREATE TABLE IF NOT EXISTS groups (
+		name VARCHAR(64) PRIMARY KEY,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE TABLE IF NOT EXISTS group_members (
+		username VARCHAR(255) PRIMARY KEY,
+		group_name VARCHAR(64) NOT NULL REFERENCES groups(name) ON DELETE CASCADE,
+		joined_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_group_members_group ON group_members(group_name, username);
+
+	-- Final standings of closed calendar windows, ranked by rating gained
+	CREATE INDEX IF NOT EXISTS idx_rating_history_time ON rating_history(board, changed_at);
+	CREATE TABLE IF NOT EXISTS window_archives (
+		board VARCHAR(64) NOT NULL REFERENCES boards(name) ON DELETE CASCADE,
+		kind VARCHAR(16) NOT NULL,
+		start_at TIMESTAMPTZ NOT NULL,
+		end_at TIMESTAMPTZ NOT NULL,
+		players INTEGER NOT NULL DEFAULT 0,
+		archived_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (board, kind, start_at)
+	);
+	CREATE TABLE IF NOT EXISTS window_standings (
+		board VARCHAR(64) NOT NULL,
+		kind VARCHAR(16) NOT NULL,
+		start_at TIMESTAMPTZ NOT NULL,
+		username VARCHAR(255) NOT NULL,
+		gain BIGINT NOT NULL,
+		rating BIGINT NOT NULL,
+		rank INTEGER NOT NULL,
+		PRIMARY KEY (board, kind, start_at, username),
+		FOREIGN KEY (board, kind, start_at) REFERENCES window_archives ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_window_standings_rank ON window_standings(board, kind, start_at, rank, username);
+
+	-- Scores are 64-bit, for boards of high scores in the billions. Tables
+	-- created before then have INTEGER columns, and only those are widened:
+	-- the ALTER takes an exclusive lock and rewrites the table, which no
+	-- restart should repeat.
+	DO $$
+	DECLARE
+		col RECORD;
+	BEGIN
+		FOR col IN
+			SELECT table_name, column_name FROM information_schema.columns
+			WHERE table_schema = current_schema() AND data_type = 'integer'
+				AND (table_name, column_name) IN (
+					('boards', 'min_rating'), ('boards', 'max_rating'),
+					('users', 'rating'),
+					('season_standings', 'rating'),
+					('rating_history', 'old_rating'), ('rating_history', 'new_rating'),
+					('window_standings', 'gain'), ('window_standings', 'rating'))
+		LOOP
+			EXECUTE format('ALTER TABLE %I ALTER COLUMN %I TYPE BIGINT', col.table_name, col.column_name);
+		END LOOP;
+	END $$;
+	`
+	_, err := pb.db.Exec(query)
+	return err
+}
+
+const boardColumns = "name, min_rating, max_rating, rating_system, k_factor, ranking_mode, tie_break, sort_order, decimals, created_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBoard(row rowScanner) (BoardConfig, error) {
+	var cfg BoardConfig
+	err := row.Scan(&cfg.Name, &cfg.MinRating, &cfg.MaxRating, &cfg.RatingSystem, &cfg.KFactor,
+		&cfg.RankingMode, &cfg.TieBreak, &cfg.Order, &cfg.Decimals, &cfg.CreatedAt)
+	return cfg, err
+}
+
+// queryBoards reads every board config from the database
+func (pb *PostgresBoards) queryBoards() ([]BoardConfig, error) {
+	rows, err := pb.db.Query("SELECT " + boardColumns + " FROM boards ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var configs []BoardConfig
+	for rows.Next() {
+		cfg, err := scanBoard(rows)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, cfg)
+	}
+	return configs, rows.Err()
+}
+
+// open builds the store for a board and loads its rank cache
+func (pb *PostgresBoards) open(cfg BoardConfig) (*PostgresStore, error) {
+	lb := &PostgresStore{
+		db:       pb.db,
+		instance: pb.instance,
+		cfg:      cfg,
+		ranks:    newRankCache(cfg),
+		feed:     NewFeed(cfg.Name),
+	}
+	if err := lb.rebuildRankCache(); err != nil {
+		return nil, err
+	}
+
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+	if existing, ok := pb.boards[cfg.Name]; ok {
+		return existing, nil
+	}
+	pb.boards[cfg.Name] = lb
+	return lb, nil
+}
+
+func (pb *PostgresBoards) Board(name string) (Store, error) {
+	pb.mu.RLock()
+	lb, ok := pb.boards[name]
+	pb.mu.RUnlock()
+	if ok {
+		return lb, nil
+	}
+
+	// The board may have been created by another server instance
+	cfg, err := scanBoard(pb.db.QueryRow("SELECT "+boardColumns+" FROM boards WHERE name = $1", name))
+	if err == sql.ErrNoRows {
+		return nil, ErrBoardNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	lb, err = pb.open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return lb, nil
+}
+
+func (pb *PostgresBoards) CreateBoard(cfg BoardConfig) (BoardConfig, error) {
+	cfg = cfg.withDefaults()
+	if err := cfg.Validate(); err != nil {
+		return BoardConfig{}, err
+	}
+
+	err := pb.db.QueryRow(`
+		INSERT INTO boards (name, min_rating, max_rating, rating_system, k_factor, ranking_mode, tie_break, sort_order, decimals)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at`, cfg.Name, cfg.MinRating, cfg.MaxRating, cfg.RatingSystem, cfg.KFactor,
+		cfg.RankingMode, cfg.TieBreak, cfg.Order, cfg.Decimals).Scan(&cfg.CreatedAt)
+	if isUniqueViolation(err) {
+		return BoardConfig{}, ErrBoardExists
+	} else if err != nil {
+		return BoardConfig{}, err
+	}
+
+	if _, err := pb.open(cfg); err != nil {
+		return BoardConfig{}, err
+	}
+	return cfg, nil
+}
+
+func (pb *PostgresBoards) DeleteBoard(name string) error {
+	if name == DefaultBoard {
+		return fmt.Errorf("%w: the default board cannot be deleted", ErrInvalidBoard)
+	}
+
+	tx, err := pb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Users of the board go with it via ON DELETE CASCADE
+	res, err := tx.Exec("DELETE FROM boards WHERE name = $1", name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrBoardNotFound
+	}
+	if err := sendNotice(tx, changeNotice{Instance: pb.instance, Board: name, Kind: noticeBoardDeleted}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	pb.mu.Lock()
+	delete(pb.boards, name)
+	pb.mu.Unlock()
+	return nil
+}
+
+func (pb *PostgresBoards) ListBoards() []BoardConfig {
+	configs, err := pb.queryBoards()
+	if err != nil {
+		log.Println("List boards error:", err)
+		return []BoardConfig{}
+	}
+	return configs
+}
+
+// WatchConsistency checks every board's rank cache against the database
+// each interval until Close is called
+func (pb *PostgresBoards) WatchConsistency(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pb.done:
+			return
+		case <-ticker.C:
+			for _, lb := range pb.stores() {
+				rebuilt, err := lb.CheckConsistency()
+				if err != nil {
+					log.Printf("Rank cache check error on board %s: %v", lb.cfg.Name, err)
+				} else if rebuilt {
+					log.Printf("Rank cache of board %s drifted from database; rebuilt", lb.cfg.Name)
+				}
+			}
+		}
+	}
+}
+
+// Close stops background work and closes the db connection
+func (pb *PostgresBoards) Close() error {
+	close(pb.done)
+	return pb.db.Close()
+}
+
+// isUniqueViolation reports whether err is a Postgres unique_violation
+func isUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505"
+}
+
+// PostgresStore is the Store for one board in Postgres.
+// Postgres is the source of truth; an in-process rankCache mirrors the
+// board's rating histogram so rank and percentile reads never scan the table.
+type PostgresStore struct {
+	db       *sql.DB
+	instance string
+	cfg      BoardConfig
+	ranks    *rankCache
+
+	// writeMu is held shared by writers from commit until their cache update
+	// lands, and exclusively while the cache is rebuilt from the database.
+	writeMu sync.RWMutex
+	// snapshot is the database snapshot the rank cache was last loaded
+	// from. Guarded by writeMu.
+	snapshot txSnapshot
+
+	feed *Feed
+}
+
+// Config returns the board this store serves
+func (lb *PostgresStore) Config() BoardConfig {
+	return lb.cfg
+}
+
+// Changes is published to after each write commits and the rank cache
+// reflects it, so subscribers re-reading ranks see the new state
+func (lb *PostgresStore) Changes() *Feed {
+	return lb.feed
+}
+
+func (lb *PostgresStore) AddUser(username string, rating int64) error {
+	if err := lb.cfg.checkRating(rating); err != nil {
+		return err
+	}
+
+	lb.writeMu.RLock()
+	defer lb.writeMu.RUnlock()
+
+	tx, err := lb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO users (board, username, rating, tie_at) VALUES ($1, $2, $3, "+lb.cfg.newTieSQL()+")",
+		lb.cfg.Name, username, rating)
+	if isUniqueViolation(err) {
+		return ErrUserExists
+	} else if err != nil {
+		return err
+	}
+	if err := lb.recordChange(tx, username, nil, rating, SourceAPI); err != nil {
+		return err
+	}
+	if err := lb.notifyUser(tx, username, nil, &rating, SourceAPI); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	lb.ranks.add(rating)
+	lb.feed.publishUser(username, nil, &rating, SourceAPI)
+	return nil
+}
+
+func (lb *PostgresStore) UpdateRating(username string, newRating int64) error {
+	return lb.SetRating(username, newRating, SourceAPI)
+}
+
+// SetRating overwrites a user's rating and records the change as coming from source
+func (lb *PostgresStore) SetRating(username string, newRating int64, source Source) error {
+	if err := lb.cfg.checkRating(newRating); err != nil {
+		return err
+	}
+
+	lb.writeMu.RLock()
+	defer lb.writeMu.RUnlock()
+
+	tx, err := lb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldRating int64
+	err = tx.QueryRow(lb.setRatingSQL(), newRating, lb.cfg.Name, username).Scan(&oldRating)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	} else if err != nil {
+		return err
+	}
+	if err := lb.recordChange(tx, username, &oldRating, newRating, source); err != nil {
+		return err
+	}
+	if err := lb.notifyUser(tx, username, &oldRating, &newRating, source); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	lb.ranks.move(oldRating, newRating)
+	lb.feed.publishUser(username, &oldRating, &newRating, source)
+	return nil
+}
+
+// setRatingSQL sets user $3's rating to $1 and returns the old one.
+// The row is locked in a subquery so we learn the rating we replaced,
+// which the rank cache needs to move the user between buckets.
+func (lb *PostgresStore) setRatingSQL() string {
+	return `
+		UPDATE users u SET rating = $1, tie_at = ` + lb.cfg.movedTieSQL("old.tie_at", "old.rating", "$1") + `
+		FROM (SELECT board, username, rating, tie_at FROM users WHERE board = $2 AND username = $3 FOR UPDATE) old
+		WHERE u.board = old.board AND u.username = old.username
+		RETURNING old.rating`
+}
+
+// IncrementRating adds delta to a user's rating in a single UPDATE, so
+// concurrent increments never overwrite each other. The sum is clamped to
+// the board bounds in SQL and computed in NUMERIC so huge deltas saturate.
+func (lb *PostgresStore) IncrementRating(username string, delta int64, source Source) (*RankedUser, error) {
+	lb.writeMu.RLock()
+	defer lb.writeMu.RUnlock()
+
+	tx, err := lb.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var oldRating, newRating int64
+	err = tx.QueryRow(`
+		UPDATE users u SET rating = old.new_rating, tie_at = `+lb.cfg.movedTieSQL("old.tie_at", "old.rating", "old.new_rating")+`
+		FROM (
+			SELECT board, username, rating, tie_at,
+				LEAST(GREATEST(rating::NUMERIC + $1::NUMERIC, $4), $5)::BIGINT AS new_rating
+			FROM users WHERE board = $2 AND username = $3 FOR UPDATE
+		) old
+		WHERE u.board = old.board AND u.username = old.username
+		RETURNING old.rating, u.rating`,
+		delta, lb.cfg.Name, username, lb.cfg.MinRating, lb.cfg.MaxRating).Scan(&oldRating, &newRating)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if newRating != oldRating {
+		if err := lb.recordChange(tx, username, &oldRating, newRating, source); err != nil {
+			return nil, err
+		}
+		if err := lb.notifyUser(tx, username, &oldRating, &newRating, source); err != nil {
+			return nil, err
+		}
+	}
+	tiedAhead, err := lb.tiedAheadTx(tx, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if newRating != oldRating {
+		lb.ranks.move(oldRating, newRating)
+		lb.feed.publishUser(username, &oldRating, &newRating, source)
+	}
+	return lb.rankWritten(username, newRating, tiedAhead), nil
+}
+
+// tiedAheadTx counts the users at username's rating ahead of them in
+// tie-break order as of tx. Only ordinal ranks count them.
+func (lb *PostgresStore) tiedAheadTx(tx *sql.Tx, username string) (int, error) {
+	if lb.cfg.RankingMode != RankOrdinal {
+		return 0, nil
+	}
+	var n int
+	err := tx.QueryRow(`
+		SELECT COUNT(*) FROM users u
+		JOIN users t ON t.board = u.board AND t.rating = u.rating
+			AND (t.tie_at, t.username) < (u.tie_at, u.username)
+		WHERE u.board = $1 AND u.username = $2`, lb.cfg.Name, username).Scan(&n)
+	return n, err
+}
+
+// rankWritten ranks a user at the rating a committed write left them at,
+// from the rank cache, instead of reading the row back after the commit
+func (lb *PostgresStore) rankWritten(username string, rating int64, tiedAhead int) *RankedUser {
+	return &RankedUser{
+		User: User{Username: username, Rating: rating},
+		Rank: lb.ranks.rank(rating) + tiedAhead,
+	}
+}
+
+// DeleteUser removes a user and their rating history. Archived season
+// standings are kept.
+func (lb *PostgresStore) DeleteUser(username string) error {
+	lb.writeMu.RLock()
+	defer lb.writeMu.RUnlock()
+
+	tx, err := lb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var rating int64
+	err = tx.QueryRow("DELETE FROM users WHERE board = $1 AND username = $2 RETURNING rating", lb.cfg.Name, username).Scan(&rating)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	} else if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM rating_history WHERE board = $1 AND username = $2", lb.cfg.Name, username); err != nil {
+		return err
+	}
+	if err := lb.notifyUser(tx, username, &rating, nil, SourceAPI); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	lb.ranks.remove(rating)
+	lb.feed.publishUser(username, &rating, nil, SourceAPI)
+	return nil
+}
+
+func (lb *PostgresStore) GetUserRank(username string) (*RankedUser, error) {
+	rows, err := lb.db.Query("SELECT "+userColumns+" FROM users WHERE board = $1 AND username = $2", lb.cfg.Name, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users, err := lb.scanRanked(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, ErrUserNotFound
+	}
+	return &users[0], nil
+}
+
+// RatingAtRank answers from the rank cache without touching the database
+func (lb *PostgresStore) RatingAtRank(rank int) (int64, error) {
+	return lb.ranks.ratingAt(rank)
+}
+
+func (lb *PostgresStore) SearchUsers(query string, limit int) []RankedUser {
+	// Search by prefix; leaderboard order is rank order
+	rows, err := lb.db.Query(`
+		SELECT `+userColumns+`
+		FROM users
+		WHERE board = $1 AND lower(username) LIKE lower($2) || '%'
+		ORDER BY `+lb.cfg.userOrder()+`
+		LIMIT $3`, lb.cfg.Name, query, limit)
+
+	if err != nil {
+		log.Println("Search error:", err)
+		return []RankedUser{}
+	}
+	defer rows.Close()
+
+	users, err := lb.scanRanked(rows)
+	if err != nil {
+		log.Println("Search error:", err)
+		return []RankedUser{}
+	}
+	return users
+}
+
+func (lb *PostgresStore) GetTopN(limit, offset int) []RankedUser {
+	// Ranks come from the cache, so this is a plain index walk
+	// instead of a RANK() window over the whole table
+	rows, err := lb.db.Query(`
+		SELECT `+userColumns+`
+		FROM users
+		WHERE board = $1
+		ORDER BY `+lb.cfg.userOrder()+`
+		LIMIT $2 OFFSET $3`, lb.cfg.Name, limit, offset)
+	if err != nil {
+		log.Println("TopN error:", err)
+		return []RankedUser{}
+	}
+	defer rows.Close()
+
+	users, err := lb.scanRanked(rows)
+	if err != nil {
+		log.Println("TopN error:", err)
+		return []RankedUser{}
+	}
+	return users
+}
+
+// GetPage seeks to the cursor in the rating index, so deep pages cost the
+// same as the first one. One extra row is read to learn whether the page
+// is the last one in its direction.
+func (lb *PostgresStore) GetPage(cursor Cursor, limit int) (*Page, error) {
+	page := &Page{}
+	var err error
+	switch {
+	case cursor.Username == "" && cursor.Backward:
+		// Nothing comes before the top of the board
+		page.Users = []RankedUser{}
+		return page, nil
+	case cursor.Username == "":
+		page.Users, err = lb.usersAfter(nil, limit+1)
+	case cursor.Backward:
+		page.Users, err = lb.usersBefore(cursor, limit+1)
+	default:
+		page.Users, err = lb.usersAfter(&cursor, limit+1)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	more := len(page.Users) > limit
+	if cursor.Backward {
+		if more {
+			page.Users = page.Users[1:]
+		}
+		page.HasPrev, page.HasNext = more, true
+	} else {
+		if more {
+			page.Users = page.Users[:limit]
+		}
+		page.HasPrev, page.HasNext = cursor.Username != "", more
+	}
+	return page, nil
+}
+
+// GetAround walks the rating index outwards from the user in both
+// directions, so the cost depends on radius and never on how far down
+// the board the user is
+func (lb *PostgresStore) GetAround(username string, radius int) ([]RankedUser, error) {
+	me, err := lb.GetUserRank(username)
+	if err != nil {
+		return nil, err
+	}
+
+	at := cursorAt(me.User, false)
+	above, err := lb.usersBefore(*at, radius)
+	if err != nil {
+		return nil, err
+	}
+	below, err := lb.usersAfter(at, radius)
+	if err != nil {
+		return nil, err
+	}
+
+	users := append(above, *me)
+	return append(users, below...), nil
+}
+
+// usersAfter returns up to limit users following the cursor in
+// leaderboard order, or from the top when cursor is nil. The cursor's
+// tie group and the ratings behind it are read as two index range scans;
+// a single row comparison cannot express the mixed sort directions.
+func (lb *PostgresStore) usersAfter(cursor *Cursor, limit int) ([]RankedUser, error) {
+	if cursor == nil {
+		rows, err := lb.db.Query(`
+			SELECT `+userColumns+` FROM users
+			WHERE board = $1
+			ORDER BY `+lb.cfg.userOrder()+`
+			LIMIT $2`, lb.cfg.Name, limit)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		return lb.scanRanked(rows)
+	}
+
+	rows, err := lb.db.Query(`
+		SELECT `+userColumns+` FROM (
+			(SELECT `+userColumns+` FROM users
+			 WHERE board = $1 AND rating = $2 AND (tie_at, username) > ($3, $4)
+			 ORDER BY tie_at ASC, username ASC LIMIT $5)
+			UNION ALL
+			(SELECT `+userColumns+` FROM users
+			 WHERE board = $1 AND rating `+lb.cfg.behindSQL()+` $2
+			 ORDER BY `+lb.cfg.userOrder()+` LIMIT $5)
+		) page_after
+		ORDER BY `+lb.cfg.userOrder()+`
+		LIMIT $5`, lb.cfg.Name, cursor.Rating, cursor.Tie, cursor.Username, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return lb.scanRanked(rows)
+}
+
+// usersBefore returns up to limit users preceding the cursor, in
+// leaderboard order. The index is walked backwards from the cursor.
+func (lb *PostgresStore) usersBefore(cursor Cursor, limit int) ([]RankedUser, error) {
+	rows, err := lb.db.Query(`
+		SELECT `+userColumns+` FROM (
+			SELECT `+userColumns+` FROM (
+				(SELECT `+userColumns+` FROM users
+				 WHERE board = $1 AND rating = $2 AND (tie_at, username) < ($3, $4)
+				 ORDER BY tie_at DESC, username DESC LIMIT $5)
+				UNION ALL
+				(SELECT `+userColumns+` FROM users
+				 WHERE board = $1 AND rating `+lb.cfg.aheadSQL()+` $2
+				 ORDER BY rating `+lb.cfg.reverseSQL()+`, tie_at DESC, username DESC LIMIT $5)
+			) candidates
+			ORDER BY rating `+lb.cfg.reverseSQL()+`, tie_at DESC, username DESC
+			LIMIT $5
+		) page_before
+		ORDER BY `+lb.cfg.userOrder(), lb.cfg.Name, cursor.Rating, cursor.Tie, cursor.Username, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return lb.scanRanked(rows)
+}
+
+// userColumns are the users columns scanRanked reads; BoardConfig.userOrder
+// is leaderboard order over them
+const userColumns = "username, rating, tie_at"
+
+// scanRanked reads userColumns rows and ranks them
+func (lb *PostgresStore) scanRanked(rows *sql.Rows) ([]RankedUser, error) {
+	var users []User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.Username, &u.Rating, &u.tie); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return lb.rank(users)
+}
+
+// rank attaches ranks under the board's ranking mode. The rank cache
+// answers competition and dense ranks; ordinal ranks also count the
+// equal-rated users ahead in tie-break order, in one query for all users.
+func (lb *PostgresStore) rank(users []User) ([]RankedUser, error) {
+	var tiedAhead map[string]int
+	if lb.cfg.RankingMode == RankOrdinal && len(users) > 0 {
+		names := make([]string, len(users))
+		for i, u := range users {
+			names[i] = u.Username
+		}
+		rows, err := lb.db.Query(`
+			SELECT u.username, (
+				SELECT COUNT(*) FROM users t
+				WHERE t.board = u.board AND t.rating = u.rating
+					AND (t.tie_at, t.username) < (u.tie_at, u.username)
+			)
+			FROM users u
+			WHERE u.board = $1 AND u.username = ANY($2)`, lb.cfg.Name, pq.Array(names))
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		tiedAhead = make(map[string]int, len(users))
+		for rows.Next() {
+			var name string
+			var n int
+			if err := rows.Scan(&name, &n); err != nil {
+				return nil, err
+			}
+			tiedAhead[name] = n
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	ranked := make([]RankedUser, len(users))
+	for i, u := range users {
+		ranked[i] = RankedUser{User: u, Rank: lb.ranks.rank(u.Rating) + tiedAhead[u.Username]}
+	}
+	return ranked, nil
+}
+
+func (lb *PostgresStore) GetStats() LeaderboardStats {
+	return lb.ranks.stats()
+}
+
+func (lb *PostgresStore) Count() int {
+	return lb.ranks.count()
+}
+
+// Seed inserts the generated users in batches, one transaction each. If
+// a batch fails, the result covers the batches already committed.
+func (lb *PostgresStore) Seed(opts SeedOptions) (*SeedResult, error) {
+	s, err := lb.cfg.newSeeder(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.opts.Clear {
+		lb.writeMu.Lock()
+		err := lb.clear()
+		lb.writeMu.Unlock()
+		if err != nil {
+			return nil, fmt.Errorf("seed clear: %w", err)
+		}
+		lb.feed.publishBulk(SourceSeed)
+	}
+
+	result := s.result()
+	for i := 0; i < s.opts.Count; i += BulkBatchSize {
+		end := min(i+BulkBatchSize, s.opts.Count)
+		if err := lb.seedBatch(s, i, end, result); err != nil {
+			return result, fmt.Errorf("seed batch at %d: %w", i, err)
+		}
+		// Log progress every 1000 users or so
+		if end%1000 == 0 || end == s.opts.Count {
+			log.Printf("Seeded %d/%d users...", end, s.opts.Count)
+		}
+	}
+	return result, nil
+}
+
+// seedBatch inserts users [start, end) of the seed in one transaction.
+// The batch is generated before anything is written, so a failure never
+// shifts the users of later batches.
+func (lb *PostgresStore) seedBatch(s *seeder, start, end int, result *SeedResult) error {
+	usernames := make([]string, 0, end-start)
+	ratings := make([]int64, 0, end-start)
+	for i := start; i < end; i++ {
+		username, rating := s.user(i)
+		usernames = append(usernames, username)
+		ratings = append(ratings, rating)
+	}
+
+	lb.writeMu.RLock()
+	defer lb.writeMu.RUnlock()
+
+	tx, err := lb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO users (board, username, rating, tie_at) VALUES ($1, $2, $3, " + lb.cfg.newTieSQL() + ") ON CONFLICT DO NOTHING")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	var inserted []int64
+	for i, username := range usernames {
+		rating := ratings[i]
+		res, err := stmt.Exec(lb.cfg.Name, username, rating)
+		if err != nil {
+			return err
+		}
+		// ON CONFLICT DO NOTHING reports 0 rows for duplicates
+		if n, _ := res.RowsAffected(); n == 1 {
+			inserted = append(inserted, rating)
+		}
+	}
+	// One notice for the batch rather than one per user, so a seed does
+	// not flood the notification queue
+	if len(inserted) > 0 {
+		if err := lb.notifyBulk(tx, SourceSeed); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, rating := range inserted {
+		lb.ranks.add(rating)
+	}
+	result.Created += len(inserted)
+	result.Skipped += end - start - len(inserted)
+	lb.feed.publishBulk(SourceSeed)
+	return nil
+}
+
+// clear deletes every user of the board and empties the rank cache.
+// Caller must hold writeMu exclusively.
+func (lb *PostgresStore) clear() error {
+	tx, err := lb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM users WHERE board = $1", lb.cfg.Name); err != nil {
+		return err
+	}
+	if err := lb.notifyBulk(tx, SourceSeed); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return lb.reloadRankCache()
+}
+
+// loadHistogram reads the rating -> user count histogram from the database,
+// along with the snapshot it was read in so changes from other instances
+// that it already includes can be told apart from later ones
+func (lb *PostgresStore) loadHistogram() (map[int64]int, txSnapshot, error) {
+	tx, err := lb.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, txSnapshot{}, err
+	}
+	defer tx.Rollback()
+
+	// The snapshot is taken by the first statement of the transaction
+	var snapText string
+	if err := tx.QueryRow("SELECT txid_current_snapshot()::TEXT").Scan(&snapText); err != nil {
+		return nil, txSnapshot{}, err
+	}
+	snap, err := parseTxSnapshot(snapText)
+	if err != nil {
+		return nil, txSnapshot{}, err
+	}
+
+	rows, err := tx.Query("SELECT rating, COUNT(*) FROM users WHERE board = $1 GROUP BY rating", lb.cfg.Name)
+	if err != nil {
+		return nil, txSnapshot{}, err
+	}
+	defer rows.Close()
+
+	hist := make(map[int64]int)
+	for rows.Next() {
+		var rating int64
+		var n int
+		if err := rows.Scan(&rating, &n); err != nil {
+			return nil, txSnapshot{}, err
+		}
+		hist[rating] = n
+	}
+	return hist, snap, rows.Err()
+}
+
+// reloadRankCache replaces the rank cache with the database contents.
+// Caller must hold writeMu exclusively.
+func (lb *PostgresStore) reloadRankCache() error {
+	hist, snap, err := lb.loadHistogram()
+	if err != nil {
+		return err
+	}
+	lb.ranks.load(hist)
+	lb.snapshot = snap
+	return nil
+}
+
+// rebuildRankCache reloads the rank cache from the database.
+// Writers are paused meanwhile so no change is counted twice or lost.
+func (lb *PostgresStore) rebuildRankCache() error {
+	lb.writeMu.Lock()
+	defer lb.writeMu.Unlock()
+	return lb.reloadRankCache()
+}
+
+// CheckConsistency compares the rank cache with the database and rebuilds
+// the cache if they have drifted. It reports whether a rebuild happened.
+func (lb *PostgresStore) CheckConsistency() (bool, error) {
+	// Cheap first pass that does not block writers
+	hist, _, err := lb.loadHistogram()
+	if err != nil {
+		return false, err
+	}
+	if sameHistogram(hist, lb.ranks.histogram()) {
+		return false, nil
+	}
+
+	// A mismatch may just be writes in flight; confirm with writers paused
+	lb.writeMu.Lock()
+	defer lb.writeMu.Unlock()
+
+	hist, snap, err := lb.loadHistogram()
+	if err != nil {
+		return false, err
+	}
+	if sameHistogram(hist, lb.ranks.histogram()) {
+		return false, nil
+	}
+	lb.ranks.load(hist)
+	lb.snapshot = snap
+	lb.feed.publishBulk(SourceSync)
+	return true, nil
+}
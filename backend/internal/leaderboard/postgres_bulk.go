@@ -0,0 +1,254 @@
+package leaderboard
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// BulkWrite applies rows in transactions of BulkBatchSize rows with
+// prepared statements, like Seed. A batch that fails is rolled back and
+// its rows reported as failed; the other batches still apply.
+func (lb *PostgresStore) BulkWrite(rows []BulkRow, mode string) ([]BulkResult, error) {
+	if err := checkBulkMode(mode); err != nil {
+		return nil, err
+	}
+	results := lb.cfg.checkBulkRows(rows)
+
+	var pending []int
+	for i := range results {
+		if results[i].Status == "" {
+			pending = append(pending, i)
+		}
+	}
+	for start := 0; start < len(pending); start += BulkBatchSize {
+		batch := pending[start:min(start+BulkBatchSize, len(pending))]
+		if err := lb.bulkBatch(rows, results, batch, mode, false); err != nil {
+			for _, i := range batch {
+				results[i].Status = BulkFailed
+				results[i].Error = err.Error()
+			}
+		}
+	}
+	return results, nil
+}
+
+// bulkMove is a rating change a bulk batch applies to the rank cache
+// once committed
+type bulkMove struct {
+	old *int64
+	new int64
+}
+
+// bulkBatch applies the rows at indexes in one transaction and fills in
+// their results. History ranks within a batch are computed before any of
+// the batch is in the rank cache. With all set, the transaction is rolled
+// back with ErrImportConflict unless every row is created.
+func (lb *PostgresStore) bulkBatch(rows []BulkRow, results []BulkResult, indexes []int, mode string, all bool) error {
+	lb.writeMu.RLock()
+	defer lb.writeMu.RUnlock()
+
+	tx, err := lb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	update, err := tx.Prepare(lb.setRatingSQL())
+	if err != nil {
+		return err
+	}
+	defer update.Close()
+	insert, err := tx.Prepare("INSERT INTO users (board, username, rating, tie_at) VALUES ($1, $2, $3, " + lb.cfg.newTieSQL() + ") ON CONFLICT DO NOTHING")
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	// updateRow reports the replaced rating, or nil if the user is missing
+	updateRow := func(username string, rating int64) (*int64, error) {
+		var old int64
+		err := update.QueryRow(rating, lb.cfg.Name, username).Scan(&old)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return &old, nil
+	}
+	insertRow := func(username string, rating int64) (bool, error) {
+		res, err := insert.Exec(lb.cfg.Name, username, rating)
+		if err != nil {
+			return false, err
+		}
+		n, _ := res.RowsAffected()
+		return n == 1, nil
+	}
+
+	statuses := make([]string, len(indexes))
+	var moves []bulkMove
+	for k, i := range indexes {
+		username, rating := rows[i].Username, *rows[i].Rating
+
+		var old *int64
+		switch mode {
+		case BulkInsert:
+			inserted, err := insertRow(username, rating)
+			if err != nil {
+				return err
+			}
+			if !inserted {
+				statuses[k] = BulkDuplicate
+				continue
+			}
+		case BulkUpdate:
+			if old, err = updateRow(username, rating); err != nil {
+				return err
+			}
+			if old == nil {
+				statuses[k] = BulkNotFound
+				continue
+			}
+		default:
+			if old, err = updateRow(username, rating); err != nil {
+				return err
+			}
+			if old == nil {
+				inserted, err := insertRow(username, rating)
+				if err != nil {
+					return err
+				}
+				// A concurrent writer created the user first; overwrite them
+				if !inserted {
+					if old, err = updateRow(username, rating); err != nil {
+						return err
+					}
+					if old == nil {
+						statuses[k] = BulkNotFound
+						continue
+					}
+				}
+			}
+		}
+
+		switch {
+		case old == nil:
+			statuses[k] = BulkCreated
+		case *old == rating:
+			statuses[k] = BulkUnchanged
+			continue
+		default:
+			statuses[k] = BulkUpdated
+		}
+		if err := lb.recordChange(tx, username, old, rating, SourceBulk); err != nil {
+			return err
+		}
+		moves = append(moves, bulkMove{old: old, new: rating})
+	}
+	// Other instances reload the board once per batch instead of applying
+	// a notice per row
+	if len(moves) > 0 {
+		if err := lb.notifyBulk(tx, SourceBulk); err != nil {
+			return err
+		}
+	}
+	if all {
+		for k, i := range indexes {
+			results[i].Status = statuses[k]
+		}
+		if err := importConflict(results); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for k, i := range indexes {
+		results[i].Status = statuses[k]
+	}
+	for _, m := range moves {
+		if m.old == nil {
+			lb.ranks.add(m.new)
+		} else {
+			lb.ranks.move(*m.old, m.new)
+		}
+	}
+	if len(moves) > 0 {
+		lb.feed.publishBulk(SourceBulk)
+	}
+	return nil
+}
+
+// InsertAll creates every row in a single transaction, which is rolled
+// back if any row is invalid or its user exists
+func (lb *PostgresStore) InsertAll(rows []BulkRow) ([]BulkResult, error) {
+	results := lb.cfg.checkBulkRows(rows)
+	indexes := make([]int, len(rows))
+	for i := range results {
+		if results[i].Status != "" {
+			// Report what the valid rows would have done as well
+			results, err := lb.PlanBulk(rows, BulkInsert)
+			if err != nil {
+				return nil, err
+			}
+			return results, importConflict(results)
+		}
+		indexes[i] = i
+	}
+	if err := lb.bulkBatch(rows, results, indexes, BulkInsert, true); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// PlanBulk reports what BulkWrite would do with rows without writing,
+// reading the current ratings of the rows' users in one query. Writes
+// that land before BulkWrite runs can still change the outcome.
+func (lb *PostgresStore) PlanBulk(rows []BulkRow, mode string) ([]BulkResult, error) {
+	if err := checkBulkMode(mode); err != nil {
+		return nil, err
+	}
+	results := lb.cfg.checkBulkRows(rows)
+
+	var names []string
+	for i, row := range rows {
+		if results[i].Status == "" {
+			names = append(names, row.Username)
+		}
+	}
+	if len(names) == 0 {
+		return results, nil
+	}
+
+	current, err := lb.db.Query("SELECT username, rating FROM users WHERE board = $1 AND username = ANY($2)", lb.cfg.Name, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+	defer current.Close()
+
+	ratings := make(map[string]int64, len(names))
+	for current.Next() {
+		var name string
+		var rating int64
+		if err := current.Scan(&name, &rating); err != nil {
+			return nil, err
+		}
+		ratings[name] = rating
+	}
+	if err := current.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, row := range rows {
+		if results[i].Status != "" {
+			continue
+		}
+		var old *int64
+		if rating, ok := ratings[row.Username]; ok {
+			old = &rating
+		}
+		results[i].Status = bulkOutcome(mode, old, *row.Rating)
+	}
+	return results, nil
+}
@@ -0,0 +1,63 @@
+package leaderboard
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Export walks the board through a server-side cursor in one read-only
+// snapshot, so memory stays flat however many users there are. Ranks are
+// counted along the walk rather than read from the rank cache, which
+// keeps moving while the export runs.
+func (lb *PostgresStore) Export(fn func(RankedUser) error) error {
+	tx, err := lb.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		DECLARE export_users NO SCROLL CURSOR FOR
+		SELECT `+userColumns+` FROM users
+		WHERE board = $1
+		ORDER BY `+lb.cfg.userOrder(), lb.cfg.Name)
+	if err != nil {
+		return err
+	}
+
+	walk := rankWalk{mode: lb.cfg.RankingMode}
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM export_users", exportFetchSize)
+	for {
+		n, err := lb.exportBatch(tx, fetch, &walk, fn)
+		if err != nil {
+			return err
+		}
+		if n < exportFetchSize {
+			return nil
+		}
+	}
+}
+
+// exportBatch fetches the next rows of the export cursor into fn and
+// returns how many there were
+func (lb *PostgresStore) exportBatch(tx *sql.Tx, fetch string, walk *rankWalk, fn func(RankedUser) error) (int, error) {
+	rows, err := tx.Query(fetch)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.Username, &u.Rating, &u.tie); err != nil {
+			return n, err
+		}
+		n++
+		if err := fn(RankedUser{User: u, Rank: walk.next(u.Rating)}); err != nil {
+			return n, err
+		}
+	}
+	return n, rows.Err()
+}
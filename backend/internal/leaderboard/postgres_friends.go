@@ -0,0 +1,103 @@
+package leaderboard
+
+import "fmt"
+
+// AddFriend makes username follow friend. Both must play on some board.
+// Adding an existing friend is a no-op.
+func (pb *PostgresBoards) AddFriend(username, friend string) error {
+	if username == "" || friend == "" {
+		return fmt.Errorf("%w: usernames are required", ErrInvalidFriend)
+	}
+	if username == friend {
+		return fmt.Errorf("%w: users cannot befriend themselves", ErrInvalidFriend)
+	}
+
+	tx, err := pb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var found int
+	err = tx.QueryRow("SELECT COUNT(DISTINCT username) FROM users WHERE username IN ($1, $2)", username, friend).Scan(&found)
+	if err != nil {
+		return err
+	}
+	if found != 2 {
+		return ErrUserNotFound
+	}
+
+	// Counting under the user's lock keeps concurrent adds within the cap
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('friends:' || $1))", username); err != nil {
+		return err
+	}
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM friends WHERE username = $1", username).Scan(&count); err != nil {
+		return err
+	}
+	if count >= MaxFriends {
+		return fmt.Errorf("%w: at most %d friends", ErrInvalidFriend, MaxFriends)
+	}
+
+	_, err = tx.Exec("INSERT INTO friends (username, friend) VALUES ($1, $2) ON CONFLICT DO NOTHING", username, friend)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (pb *PostgresBoards) RemoveFriend(username, friend string) error {
+	res, err := pb.db.Exec("DELETE FROM friends WHERE username = $1 AND friend = $2", username, friend)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrFriendNotFound
+	}
+	return nil
+}
+
+func (pb *PostgresBoards) ListFriends(username string) ([]Friend, error) {
+	rows, err := pb.db.Query(`
+		SELECT friend, created_at FROM friends
+		WHERE username = $1
+		ORDER BY friend`, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	friends := []Friend{}
+	for rows.Next() {
+		var f Friend
+		if err := rows.Scan(&f.Username, &f.Since); err != nil {
+			return nil, err
+		}
+		friends = append(friends, f)
+	}
+	return friends, rows.Err()
+}
+
+func (lb *PostgresStore) FriendsLeaderboard(username string) ([]FriendRank, error) {
+	rows, err := lb.db.Query(`
+		SELECT `+userColumns+` FROM users
+		WHERE board = $1 AND (username = $2 OR username IN (
+			SELECT friend FROM friends WHERE username = $2
+		))`, lb.cfg.Name, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users, err := lb.scanRanked(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range users {
+		if u.Username == username {
+			return rankAmong(users, lb.cfg), nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
@@ -0,0 +1,190 @@
+package leaderboard
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// windowSettle delays archiving a closed window so writes stamped just
+// before it ended have committed
+const windowSettle = time.Minute
+
+// windowGainsSQL ranks users of board $1 by rating gained in [$2, $3).
+// Consecutive history rows chain old to new rating, so the deltas sum to
+// the net change over the window. On ascending boards the biggest drop
+// ranks first.
+func windowGainsSQL(cfg BoardConfig) string {
+	return `
+	SELECT username, gain, rating, RANK() OVER (ORDER BY gain ` + cfg.orderSQL() + `) AS rank
+	FROM (
+		SELECT username,
+			COALESCE(SUM(new_rating - COALESCE(old_rating, new_rating)) FILTER (WHERE source <> '` + string(SourceSeason) + `'), 0) AS gain,
+			(ARRAY_AGG(new_rating ORDER BY changed_at DESC, id DESC))[1] AS rating
+		FROM rating_history
+		WHERE board = $1 AND changed_at >= $2 AND changed_at < $3
+		GROUP BY username
+	) gains`
+}
+
+func (lb *PostgresStore) WindowLeaderboard(w Window, limit, offset int) ([]WindowRank, int, error) {
+	now := time.Now()
+	if !w.Rolling && w.Closed(now) {
+		ranks, total, err := lb.archivedWindow(w, limit, offset)
+		if err != ErrWindowNotFound {
+			return ranks, total, err
+		}
+		if !w.retained(now) {
+			return nil, 0, ErrWindowNotFound
+		}
+		// Closed but not archived yet; the history is still there
+	}
+
+	rows, err := lb.db.Query(windowGainsSQL(lb.cfg)+`
+		ORDER BY gain `+lb.cfg.orderSQL()+`, username ASC
+		LIMIT $4 OFFSET $5`, lb.cfg.Name, w.Start, w.End, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	ranks, err := scanWindowRanks(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	err = lb.db.QueryRow(`
+		SELECT COUNT(DISTINCT username) FROM rating_history
+		WHERE board = $1 AND changed_at >= $2 AND changed_at < $3`, lb.cfg.Name, w.Start, w.End).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ranks, total, nil
+}
+
+// archivedWindow reads a closed window's frozen standings
+func (lb *PostgresStore) archivedWindow(w Window, limit, offset int) ([]WindowRank, int, error) {
+	var players int
+	err := lb.db.QueryRow(`
+		SELECT players FROM window_archives
+		WHERE board = $1 AND kind = $2 AND start_at = $3`, lb.cfg.Name, w.Kind, w.Start).Scan(&players)
+	if err == sql.ErrNoRows {
+		return nil, 0, ErrWindowNotFound
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := lb.db.Query(`
+		SELECT username, gain, rating, rank FROM window_standings
+		WHERE board = $1 AND kind = $2 AND start_at = $3
+		ORDER BY rank ASC, username ASC
+		LIMIT $4 OFFSET $5`, lb.cfg.Name, w.Kind, w.Start, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	ranks, err := scanWindowRanks(rows)
+	return ranks, players, err
+}
+
+func scanWindowRanks(rows *sql.Rows) ([]WindowRank, error) {
+	defer rows.Close()
+
+	ranks := []WindowRank{}
+	for rows.Next() {
+		var r WindowRank
+		if err := rows.Scan(&r.Username, &r.Gain, &r.Rating, &r.Rank); err != nil {
+			return nil, err
+		}
+		ranks = append(ranks, r)
+	}
+	return ranks, rows.Err()
+}
+
+// RollOverWindows archives closed windows newest first, stopping at the
+// first one already archived, so a restart after downtime backfills
+// every window still within retention
+func (lb *PostgresStore) RollOverWindows(now time.Time) error {
+	settled := now.Add(-windowSettle)
+	for _, kind := range WindowKinds {
+		current, _ := CalendarWindow(kind, settled)
+		oldest := oldestRetained(kind, settled)
+
+		for w := current.Previous(); !w.Start.Before(oldest.Start); w = w.Previous() {
+			if w.End.Before(lb.cfg.CreatedAt) {
+				break
+			}
+			archived, err := lb.archiveWindow(w)
+			if err != nil {
+				return err
+			}
+			if !archived {
+				break
+			}
+		}
+
+		_, err := lb.db.Exec(`
+			DELETE FROM window_archives
+			WHERE board = $1 AND kind = $2 AND start_at < $3`, lb.cfg.Name, kind, oldest.Start)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveWindow freezes w's standings, returning false if another call
+// (possibly on another instance) already did
+func (lb *PostgresStore) archiveWindow(w Window) (bool, error) {
+	tx, err := lb.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO window_archives (board, kind, start_at, end_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING`, lb.cfg.Name, w.Kind, w.Start, w.End)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	res, err = tx.Exec(`
+		INSERT INTO window_standings (board, kind, start_at, username, gain, rating, rank)
+		SELECT $1, $4, $2, username, gain, rating, rank
+		FROM (`+windowGainsSQL(lb.cfg)+`) ranked`, lb.cfg.Name, w.Start, w.End, w.Kind)
+	if err != nil {
+		return false, err
+	}
+	players, _ := res.RowsAffected()
+	_, err = tx.Exec(`
+		UPDATE window_archives SET players = $4
+		WHERE board = $1 AND kind = $2 AND start_at = $3`, lb.cfg.Name, w.Kind, w.Start, players)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// WatchWindows rolls every board's windows over each interval until
+// Close is called
+func (pb *PostgresBoards) WatchWindows(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, lb := range pb.stores() {
+			if err := lb.RollOverWindows(time.Now()); err != nil {
+				log.Printf("Window rollover error on board %s: %v", lb.cfg.Name, err)
+			}
+		}
+
+		select {
+		case <-pb.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
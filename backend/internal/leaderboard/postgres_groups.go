@@ -0,0 +1,260 @@
+package leaderboard
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// groupScoresSQL ranks every group with members on board $1 by the
+// aggregate $2, averaging only each group's best $3 members under topk.
+// Scores rank in the board's order.
+func groupScoresSQL(cfg BoardConfig) string {
+	return `
+	WITH members AS (
+		SELECT gm.group_name, u.rating,
+			ROW_NUMBER() OVER (PARTITION BY gm.group_name ORDER BY u.rating ` + cfg.orderSQL() + `) AS pos
+		FROM group_members gm
+		JOIN users u ON u.board = $1 AND u.username = gm.username
+	), scores AS (
+		SELECT group_name, COUNT(*) AS members,
+			CASE $2::TEXT
+				WHEN 'sum' THEN SUM(rating)::FLOAT8
+				WHEN 'topk' THEN (AVG(rating) FILTER (WHERE pos <= $3::INTEGER))::FLOAT8
+				ELSE AVG(rating)::FLOAT8
+			END AS score
+		FROM members
+		GROUP BY group_name
+	), ranked AS (
+		SELECT group_name, members, score, RANK() OVER (ORDER BY score ` + cfg.orderSQL() + `) AS rank
+		FROM scores
+	)`
+}
+
+func (pb *PostgresBoards) CreateGroup(name string) (*Group, error) {
+	if err := validateGroupName(name); err != nil {
+		return nil, err
+	}
+
+	g := Group{Name: name}
+	err := pb.db.QueryRow("INSERT INTO groups (name) VALUES ($1) RETURNING created_at", name).Scan(&g.CreatedAt)
+	if isUniqueViolation(err) {
+		return nil, ErrGroupExists
+	} else if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// DeleteGroup disbands the group; its members become free to join another
+func (pb *PostgresBoards) DeleteGroup(name string) error {
+	res, err := pb.db.Exec("DELETE FROM groups WHERE name = $1", name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrGroupNotFound
+	}
+	return nil
+}
+
+func (pb *PostgresBoards) ListGroups() ([]Group, error) {
+	rows, err := pb.db.Query(`
+		SELECT g.name, COUNT(gm.username), g.created_at
+		FROM groups g
+		LEFT JOIN group_members gm ON gm.group_name = g.name
+		GROUP BY g.name
+		ORDER BY g.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []Group{}
+	for rows.Next() {
+		var g Group
+		if err := rows.Scan(&g.Name, &g.Members, &g.CreatedAt); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// JoinGroup adds the user, who must play on some board, to the group.
+// Joining the group the user is already in is a no-op.
+func (pb *PostgresBoards) JoinGroup(group, username string) error {
+	tx, err := pb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Share-locking the group keeps it from being deleted under the insert
+	var found string
+	err = tx.QueryRow("SELECT name FROM groups WHERE name = $1 FOR SHARE", group).Scan(&found)
+	if err == sql.ErrNoRows {
+		return ErrGroupNotFound
+	} else if err != nil {
+		return err
+	}
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE username = $1)", username).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	// On conflict the no-op update returns the group the user is already in
+	var current string
+	err = tx.QueryRow(`
+		INSERT INTO group_members (username, group_name) VALUES ($1, $2)
+		ON CONFLICT (username) DO UPDATE SET group_name = group_members.group_name
+		RETURNING group_name`, username, group).Scan(&current)
+	if err != nil {
+		return err
+	}
+	if current != group {
+		return fmt.Errorf("%w: %s", ErrAlreadyInGroup, current)
+	}
+	return tx.Commit()
+}
+
+func (pb *PostgresBoards) LeaveGroup(group, username string) error {
+	res, err := pb.db.Exec("DELETE FROM group_members WHERE group_name = $1 AND username = $2", group, username)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+	if err := groupExists(pb.db, group); err != nil {
+		return err
+	}
+	return ErrNotGroupMember
+}
+
+// groupExists returns ErrGroupNotFound unless the group exists
+func groupExists(db *sql.DB, name string) error {
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM groups WHERE name = $1)", name).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrGroupNotFound
+	}
+	return nil
+}
+
+func (lb *PostgresStore) GroupLeaderboard(agg GroupAggregate, limit, offset int) ([]RankedGroup, int, error) {
+	agg = agg.WithDefaults()
+	if err := agg.Validate(); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := lb.db.Query(groupScoresSQL(lb.cfg)+`
+		SELECT group_name, members, score, rank, COUNT(*) OVER ()
+		FROM ranked
+		ORDER BY score `+lb.cfg.orderSQL()+`, group_name ASC
+		LIMIT $4 OFFSET $5`, lb.cfg.Name, agg.Mode, agg.K, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	groups := []RankedGroup{}
+	total := 0
+	for rows.Next() {
+		var g RankedGroup
+		if err := rows.Scan(&g.Name, &g.Members, &g.Score, &g.Rank, &total); err != nil {
+			return nil, 0, err
+		}
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// Past the last page the window count is lost with the rows
+	if len(groups) == 0 && offset > 0 {
+		err := lb.db.QueryRow(`
+			SELECT COUNT(DISTINCT gm.group_name)
+			FROM group_members gm
+			JOIN users u ON u.board = $1 AND u.username = gm.username`, lb.cfg.Name).Scan(&total)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return groups, total, nil
+}
+
+// GetGroupRank reports ErrGroupNotFound for groups with no members on the board
+func (lb *PostgresStore) GetGroupRank(name string, agg GroupAggregate) (*RankedGroup, error) {
+	agg = agg.WithDefaults()
+	if err := agg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var g RankedGroup
+	err := lb.db.QueryRow(groupScoresSQL(lb.cfg)+`
+		SELECT group_name, members, score, rank
+		FROM ranked
+		WHERE group_name = $4`, lb.cfg.Name, agg.Mode, agg.K, name).Scan(&g.Name, &g.Members, &g.Score, &g.Rank)
+	if err == sql.ErrNoRows {
+		if err := groupExists(lb.db, name); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: no members on board %s", ErrGroupNotFound, lb.cfg.Name)
+	} else if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (lb *PostgresStore) GroupMembers(name string, limit, offset int) ([]MemberRank, error) {
+	rows, err := lb.db.Query(`
+		SELECT `+userColumns+`, group_rank FROM (
+			SELECT u.username, u.rating, u.tie_at,
+				`+lb.cfg.rankWindowSQL("u.rating", "u.tie_at", "u.username")+` AS group_rank
+			FROM group_members gm
+			JOIN users u ON u.board = $1 AND u.username = gm.username
+			WHERE gm.group_name = $2
+		) members
+		ORDER BY `+lb.cfg.userOrder()+`
+		LIMIT $3 OFFSET $4`, lb.cfg.Name, name, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	var groupRanks []int
+	for rows.Next() {
+		var u User
+		var groupRank int
+		if err := rows.Scan(&u.Username, &u.Rating, &u.tie, &groupRank); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+		groupRanks = append(groupRanks, groupRank)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ranked, err := lb.rank(users)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]MemberRank, len(ranked))
+	for i, r := range ranked {
+		members[i] = MemberRank{RankedUser: r, GroupRank: groupRanks[i]}
+	}
+	if len(members) == 0 {
+		if err := groupExists(lb.db, name); err != nil {
+			return nil, err
+		}
+	}
+	return members, nil
+}
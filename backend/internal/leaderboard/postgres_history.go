@@ -0,0 +1,94 @@
+package leaderboard
+
+import (
+	"database/sql"
+	"time"
+)
+
+// recordChange appends a rating history row inside tx, after the user's
+// row has been written. The stored rank is the one the user holds once
+// the rank cache reflects the change.
+func (lb *PostgresStore) recordChange(tx *sql.Tx, username string, oldRating *int64, newRating int64, source Source) error {
+	rank := lb.ranks.projectedRank(oldRating, newRating)
+	if lb.cfg.RankingMode == RankOrdinal {
+		// The transaction sees the user's new rating and tie key
+		var tiedAhead int
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM users t
+			JOIN users me ON me.board = t.board AND me.username = $2
+			WHERE t.board = $1 AND t.rating = me.rating
+				AND (t.tie_at, t.username) < (me.tie_at, me.username)`, lb.cfg.Name, username).Scan(&tiedAhead)
+		if err != nil {
+			return err
+		}
+		rank += tiedAhead
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO rating_history (board, username, old_rating, new_rating, rank, source)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		lb.cfg.Name, username, oldRating, newRating, rank, source)
+	return err
+}
+
+func (lb *PostgresStore) RatingHistory(username string, from, to time.Time) (*RatingHistory, error) {
+	current, err := lb.GetUserRank(username)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &RatingHistory{
+		Username:   username,
+		Changes:    []RatingChange{},
+		PeakRating: current.Rating,
+		PeakRank:   current.Rank,
+	}
+
+	var peakRating, peakRank sql.NullInt64
+	err = lb.db.QueryRow(`
+		SELECT `+lb.cfg.bestSQL()+`(new_rating), MIN(rank) FROM rating_history
+		WHERE board = $1 AND username = $2`, lb.cfg.Name, username).Scan(&peakRating, &peakRank)
+	if err != nil {
+		return nil, err
+	}
+	if peakRating.Valid && lb.cfg.ahead(peakRating.Int64, h.PeakRating) {
+		h.PeakRating = peakRating.Int64
+	}
+	if peakRank.Valid && int(peakRank.Int64) < h.PeakRank {
+		h.PeakRank = int(peakRank.Int64)
+	}
+
+	// NULL bounds leave the window open on that side
+	var fromArg, toArg interface{}
+	if !from.IsZero() {
+		fromArg = from
+	}
+	if !to.IsZero() {
+		toArg = to
+	}
+
+	rows, err := lb.db.Query(`
+		SELECT old_rating, new_rating, rank, source, changed_at FROM rating_history
+		WHERE board = $1 AND username = $2
+			AND ($3::TIMESTAMPTZ IS NULL OR changed_at >= $3)
+			AND ($4::TIMESTAMPTZ IS NULL OR changed_at < $4)
+		ORDER BY changed_at ASC, id ASC`, lb.cfg.Name, username, fromArg, toArg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c RatingChange
+		var old sql.NullInt64
+		if err := rows.Scan(&old, &c.NewRating, &c.Rank, &c.Source, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		if old.Valid {
+			v := old.Int64
+			c.OldRating = &v
+		}
+		h.Changes = append(h.Changes, c)
+	}
+	return h, rows.Err()
+}
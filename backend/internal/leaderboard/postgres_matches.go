@@ -0,0 +1,253 @@
+package leaderboard
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RecordMatch applies a match result to every player in one transaction
+func (lb *PostgresStore) RecordMatch(m Match) (*MatchResult, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	if lb.cfg.ascending() {
+		// Rating systems raise winners, which would sink them on these boards
+		return nil, fmt.Errorf("%w: ascending boards do not rate matches", ErrInvalidMatch)
+	}
+
+	lb.writeMu.RLock()
+	defer lb.writeMu.RUnlock()
+
+	tx, err := lb.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock players in a stable order so concurrent matches cannot deadlock
+	rows, err := tx.Query(`
+		SELECT username, rating FROM users
+		WHERE board = $1 AND username = ANY($2)
+		ORDER BY username
+		FOR UPDATE`, lb.cfg.Name, pq.Array(m.players()))
+	if err != nil {
+		return nil, err
+	}
+	ratings := make(map[string]int64)
+	for rows.Next() {
+		var name string
+		var rating int64
+		if err := rows.Scan(&name, &rating); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ratings[name] = rating
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if missing := missingPlayers(m, ratings); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %v", ErrUserNotFound, missing)
+	}
+
+	// Batched systems only queue the match; CloseRatingPeriod rates it
+	if lb.cfg.ratingSystem().Batched() {
+		result := &MatchResult{Pending: true, Players: []PlayerDelta{}}
+		err := tx.QueryRow(`
+			INSERT INTO matches (board, team_a, team_b, winner, rated) VALUES ($1, $2, $3, $4, FALSE)
+			RETURNING id`, lb.cfg.Name, pq.Array(m.TeamA), pq.Array(m.TeamB), m.Winner).Scan(&result.ID)
+		if err != nil {
+			return nil, err
+		}
+		return result, tx.Commit()
+	}
+
+	deltas, err := rateMatch(lb.cfg, m, ratings)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deltas {
+		_, err := tx.Exec("UPDATE users SET rating = $1, tie_at = "+lb.cfg.movedTieSQL("tie_at", "rating", "$1")+" WHERE board = $2 AND username = $3",
+			d.NewRating, lb.cfg.Name, d.Username)
+		if err != nil {
+			return nil, err
+		}
+		if err := lb.recordChange(tx, d.Username, &d.OldRating, d.NewRating, SourceMatch); err != nil {
+			return nil, err
+		}
+		if err := lb.notifyUser(tx, d.Username, &d.OldRating, &d.NewRating, SourceMatch); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &MatchResult{Players: deltas}
+	err = tx.QueryRow(`
+		INSERT INTO matches (board, team_a, team_b, winner) VALUES ($1, $2, $3, $4)
+		RETURNING id`, lb.cfg.Name, pq.Array(m.TeamA), pq.Array(m.TeamB), m.Winner).Scan(&result.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for _, d := range deltas {
+		lb.ranks.move(d.OldRating, d.NewRating)
+		lb.feed.publishUser(d.Username, &d.OldRating, &d.NewRating, SourceMatch)
+	}
+	return result, nil
+}
+
+// CloseRatingPeriod rates the board's pending matches in one transaction.
+// Players who sat the period out have their deviation grown in place.
+// Writers on this instance are paused until the rank cache is reloaded.
+func (lb *PostgresStore) CloseRatingPeriod() (*RatingPeriod, error) {
+	if !lb.cfg.ratingSystem().Batched() {
+		return nil, fmt.Errorf("%w: %s boards rate matches immediately", ErrNotBatched, lb.cfg.RatingSystem)
+	}
+
+	lb.writeMu.Lock()
+	defer lb.writeMu.Unlock()
+
+	tx, err := lb.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Locking the board row serializes period closes across server instances
+	if _, err := tx.Exec("SELECT 1 FROM boards WHERE name = $1 FOR UPDATE", lb.cfg.Name); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, team_a, team_b, winner FROM matches
+		WHERE board = $1 AND NOT rated
+		ORDER BY id`, lb.cfg.Name)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	var matches []Match
+	for rows.Next() {
+		var id int64
+		var m Match
+		if err := rows.Scan(&id, (*pq.StringArray)(&m.TeamA), (*pq.StringArray)(&m.TeamB), &m.Winner); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+		matches = append(matches, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Everyone is rated against pre-period state
+	var names []string
+	for _, m := range matches {
+		names = append(names, m.players()...)
+	}
+	rows, err = tx.Query(`
+		SELECT username, rating, deviation, volatility FROM users
+		WHERE board = $1 AND username = ANY($2)
+		ORDER BY username
+		FOR UPDATE`, lb.cfg.Name, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+	players := make(map[string]PlayerRating)
+	for rows.Next() {
+		var name string
+		var p PlayerRating
+		if err := rows.Scan(&name, &p.Rating, &p.Deviation, &p.Volatility); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		players[name] = p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rated := ratePeriod(lb.cfg, matches, players)
+	var (
+		// Never nil: a nil slice is sent as NULL, which would spare the
+		// inactive players below in a period nobody played
+		usernames                = []string{}
+		oldRatings, newRatings   []int64
+		deviations, volatilities []float64
+	)
+	for _, p := range rated {
+		usernames = append(usernames, p.Username)
+		oldRatings = append(oldRatings, int64(p.OldRating))
+		newRatings = append(newRatings, int64(p.NewRating))
+		deviations = append(deviations, p.Deviation)
+		volatilities = append(volatilities, p.Volatility)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users u
+		SET rating = c.rating, tie_at = `+lb.cfg.movedTieSQL("u.tie_at", "u.rating", "c.rating")+`,
+			deviation = c.deviation, volatility = c.volatility
+		FROM unnest($2::TEXT[], $3::BIGINT[], $4::FLOAT8[], $5::FLOAT8[]) AS c(username, rating, deviation, volatility)
+		WHERE u.board = $1 AND u.username = c.username`,
+		lb.cfg.Name, pq.Array(usernames), pq.Array(newRatings), pq.Array(deviations), pq.Array(volatilities))
+	if err != nil {
+		return nil, err
+	}
+
+	// Glicko-2 step 6 for inactive players, in Glicko units
+	_, err = tx.Exec(`
+		UPDATE users
+		SET deviation = LEAST(SQRT(deviation * deviation + POWER($2::FLOAT8 * volatility, 2)), $3::FLOAT8)
+		WHERE board = $1 AND username <> ALL($4::TEXT[])`,
+		lb.cfg.Name, glicko2Scale, DefaultDeviation, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+
+	// History ranks are taken after every rating in the period has moved
+	_, err = tx.Exec(`
+		INSERT INTO rating_history (board, username, old_rating, new_rating, rank, source)
+		SELECT $1, c.username, c.old_rating, c.new_rating, r.rank, $2
+		FROM unnest($3::TEXT[], $4::BIGINT[], $5::BIGINT[]) AS c(username, old_rating, new_rating)
+		JOIN (
+			SELECT username, `+lb.cfg.rankWindowSQL("rating", "tie_at", "username")+` AS rank
+			FROM users WHERE board = $1
+		) r ON r.username = c.username
+		WHERE c.old_rating <> c.new_rating`,
+		lb.cfg.Name, SourceMatch, pq.Array(usernames), pq.Array(oldRatings), pq.Array(newRatings))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("UPDATE matches SET rated = TRUE WHERE id = ANY($1)", pq.Array(ids)); err != nil {
+		return nil, err
+	}
+	if err := lb.notifyBulk(tx, SourceMatch); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if err := lb.reloadRankCache(); err != nil {
+		return nil, err
+	}
+	lb.feed.publishBulk(SourceMatch)
+
+	return &RatingPeriod{
+		Board:    lb.cfg.Name,
+		Matches:  len(matches),
+		Players:  rated,
+		ClosedAt: time.Now(),
+	}, nil
+}
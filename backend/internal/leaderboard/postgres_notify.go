@@ -0,0 +1,244 @@
+package leaderboard
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// notifyChannel carries rating changes between server instances sharing
+// a database. Every write sends a notice from inside its transaction, so
+// it is delivered if and only if the write commits.
+const notifyChannel = "leaderboard_changes"
+
+// Notice kinds
+const (
+	noticeUser         = "user"          // one user's rating changed
+	noticeBulk         = "bulk"          // many users changed; reload the board
+	noticeBoardDeleted = "board_deleted" // drop the board
+)
+
+// changeNotice is the NOTIFY payload. Keys are short because payloads are
+// capped at 8000 bytes.
+type changeNotice struct {
+	Instance string `json:"i"`
+	Board    string `json:"b"`
+	Kind     string `json:"k"`
+	Username string `json:"u,omitempty"`
+	Old      *int64 `json:"o,omitempty"`
+	New      *int64 `json:"n,omitempty"`
+	Source   Source `json:"s,omitempty"`
+	Xid      int64  `json:"x"` // filled in by the database
+}
+
+// newInstanceID identifies this process so it can skip its own notices
+func newInstanceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// sendNotice sends n from inside tx, stamped with the transaction's id
+func sendNotice(tx *sql.Tx, n changeNotice) error {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`SELECT pg_notify($1, ($2::JSONB || jsonb_build_object('x', txid_current()))::TEXT)`,
+		notifyChannel, string(payload))
+	return err
+}
+
+// notify sends a notice about the store's board
+func (lb *PostgresStore) notify(tx *sql.Tx, n changeNotice) error {
+	n.Instance = lb.instance
+	n.Board = lb.cfg.Name
+	return sendNotice(tx, n)
+}
+
+// notifyUser sends a single user's rating change; nil ratings mean the
+// user was created or deleted
+func (lb *PostgresStore) notifyUser(tx *sql.Tx, username string, oldRating, newRating *int64, source Source) error {
+	return lb.notify(tx, changeNotice{Kind: noticeUser, Username: username, Old: oldRating, New: newRating, Source: source})
+}
+
+// notifyBulk tells other instances to reload the board
+func (lb *PostgresStore) notifyBulk(tx *sql.Tx, source Source) error {
+	return lb.notify(tx, changeNotice{Kind: noticeBulk, Source: source})
+}
+
+// txSnapshot is a parsed txid_current_snapshot(): transactions below xmin
+// had finished, those at or above xmax had not started, and those in xip
+// were in progress
+type txSnapshot struct {
+	xmin, xmax int64
+	xip        map[int64]bool
+}
+
+func parseTxSnapshot(s string) (txSnapshot, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return txSnapshot{}, fmt.Errorf("malformed snapshot %q", s)
+	}
+	var snap txSnapshot
+	var err error
+	if snap.xmin, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+		return txSnapshot{}, fmt.Errorf("malformed snapshot %q: %w", s, err)
+	}
+	if snap.xmax, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return txSnapshot{}, fmt.Errorf("malformed snapshot %q: %w", s, err)
+	}
+	snap.xip = make(map[int64]bool)
+	if parts[2] != "" {
+		for _, x := range strings.Split(parts[2], ",") {
+			xid, err := strconv.ParseInt(x, 10, 64)
+			if err != nil {
+				return txSnapshot{}, fmt.Errorf("malformed snapshot %q: %w", s, err)
+			}
+			snap.xip[xid] = true
+		}
+	}
+	return snap, nil
+}
+
+// visible reports whether the effects of transaction xid are included in
+// data read under the snapshot. The zero snapshot sees nothing.
+func (s txSnapshot) visible(xid int64) bool {
+	return xid < s.xmin || (xid < s.xmax && !s.xip[xid])
+}
+
+// applyRemote applies another instance's change to the rank cache and
+// republishes it to local subscribers. Changes the cache was loaded
+// after are skipped so nothing is counted twice.
+func (lb *PostgresStore) applyRemote(n changeNotice) {
+	lb.writeMu.RLock()
+	if lb.snapshot.visible(n.Xid) {
+		lb.writeMu.RUnlock()
+		return
+	}
+	for _, r := range []*int64{n.Old, n.New} {
+		if r != nil && lb.cfg.checkRating(*r) != nil {
+			// Only possible if the board was recreated with other bounds
+			lb.writeMu.RUnlock()
+			lb.resync()
+			return
+		}
+	}
+	switch {
+	case n.Old == nil && n.New != nil:
+		lb.ranks.add(*n.New)
+	case n.Old != nil && n.New == nil:
+		lb.ranks.remove(*n.Old)
+	case n.Old != nil && n.New != nil:
+		lb.ranks.move(*n.Old, *n.New)
+	}
+	lb.writeMu.RUnlock()
+
+	lb.feed.publishUser(n.Username, n.Old, n.New, n.Source)
+}
+
+// resync reloads the rank cache and tells local subscribers to re-read
+func (lb *PostgresStore) resync() {
+	if err := lb.rebuildRankCache(); err != nil {
+		log.Printf("Rank cache resync error on board %s: %v", lb.cfg.Name, err)
+		return
+	}
+	lb.feed.publishBulk(SourceSync)
+}
+
+// listen applies other instances' changes until Close is called.
+// Notices sent while the listener is disconnected are lost, so every
+// board is resynced after a reconnect.
+func (pb *PostgresBoards) listen(dsn string) error {
+	events := func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Change listener: %v", err)
+		}
+	}
+	l := pq.NewListener(dsn, time.Second, time.Minute, events)
+	if err := l.Listen(notifyChannel); err != nil {
+		l.Close()
+		return err
+	}
+
+	go func() {
+		defer l.Close()
+
+		// Pings detect a dead connection that would otherwise sit idle
+		ping := time.NewTicker(time.Minute)
+		defer ping.Stop()
+
+		for {
+			select {
+			case <-pb.done:
+				return
+			case <-ping.C:
+				go l.Ping()
+			case n := <-l.Notify:
+				if n == nil {
+					// pq sends nil after re-establishing the connection
+					log.Println("Change listener reconnected; resyncing boards")
+					for _, lb := range pb.stores() {
+						lb.resync()
+					}
+					continue
+				}
+				pb.handleNotice(n.Extra)
+			}
+		}
+	}()
+	return nil
+}
+
+func (pb *PostgresBoards) handleNotice(payload string) {
+	var n changeNotice
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		log.Printf("Change listener: bad notice %q: %v", payload, err)
+		return
+	}
+	if n.Instance == pb.instance {
+		return
+	}
+
+	if n.Kind == noticeBoardDeleted {
+		pb.mu.Lock()
+		delete(pb.boards, n.Board)
+		pb.mu.Unlock()
+		return
+	}
+
+	// Boards not loaded yet will read fresh state when first used
+	pb.mu.RLock()
+	lb, ok := pb.boards[n.Board]
+	pb.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	switch n.Kind {
+	case noticeUser:
+		lb.applyRemote(n)
+	case noticeBulk:
+		lb.resync()
+	}
+}
+
+// stores returns the loaded board stores
+func (pb *PostgresBoards) stores() []*PostgresStore {
+	pb.mu.RLock()
+	defer pb.mu.RUnlock()
+
+	stores := make([]*PostgresStore, 0, len(pb.boards))
+	for _, lb := range pb.boards {
+		stores = append(stores, lb)
+	}
+	return stores
+}
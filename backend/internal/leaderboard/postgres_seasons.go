@@ -0,0 +1,207 @@
+package leaderboard
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const seasonColumns = "id, board, name, started_at, ended_at, players, reset_mode"
+
+func scanSeason(row rowScanner) (Season, error) {
+	var s Season
+	err := row.Scan(&s.ID, &s.Board, &s.Name, &s.StartedAt, &s.EndedAt, &s.Players, &s.ResetMode)
+	return s, err
+}
+
+// EndSeason archives the board's current ranking and applies reset, all in
+// one transaction. Writers on this instance are paused until the rank
+// cache has been reloaded.
+func (lb *PostgresStore) EndSeason(name string, reset SeasonReset) (*Season, error) {
+	if reset.Mode == "" {
+		reset.Mode = ResetNone
+	}
+	if err := reset.Validate(lb.cfg); err != nil {
+		return nil, err
+	}
+
+	lb.writeMu.Lock()
+	defer lb.writeMu.Unlock()
+
+	tx, err := lb.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Locking the board row serializes rollovers across server instances
+	var boardCreated sql.NullTime
+	if err := tx.QueryRow("SELECT created_at FROM boards WHERE name = $1 FOR UPDATE", lb.cfg.Name).Scan(&boardCreated); err != nil {
+		return nil, err
+	}
+
+	// The season being closed started when the previous one ended
+	var lastEnded sql.NullTime
+	var closed int
+	err = tx.QueryRow("SELECT MAX(ended_at), COUNT(*) FROM seasons WHERE board = $1", lb.cfg.Name).Scan(&lastEnded, &closed)
+	if err != nil {
+		return nil, err
+	}
+	started := boardCreated.Time
+	if lastEnded.Valid {
+		started = lastEnded.Time
+	}
+	if name == "" {
+		name = fmt.Sprintf("Season %d", closed+1)
+	}
+
+	season, err := scanSeason(tx.QueryRow(`
+		INSERT INTO seasons (board, name, started_at, reset_mode)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+seasonColumns, lb.cfg.Name, name, started, reset.Mode))
+	if isUniqueViolation(err) {
+		return nil, ErrSeasonExists
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Freeze the final standings ranked as GetTopN ranks them, under the
+	// board's ranking mode and tie-break
+	res, err := tx.Exec(`
+		INSERT INTO season_standings (season_id, username, rating, rank)
+		SELECT $1, username, rating, `+lb.cfg.rankWindowSQL("rating", "tie_at", "username")+`
+		FROM users WHERE board = $2`, season.ID, lb.cfg.Name)
+	if err != nil {
+		return nil, err
+	}
+	players, _ := res.RowsAffected()
+	season.Players = int(players)
+	if _, err := tx.Exec("UPDATE seasons SET players = $1 WHERE id = $2", season.Players, season.ID); err != nil {
+		return nil, err
+	}
+
+	switch reset.Mode {
+	case ResetFresh:
+		_, err = tx.Exec("DELETE FROM users WHERE board = $1", lb.cfg.Name)
+	case ResetSoft:
+		// Reset and log the change of every user whose rating moved in one
+		// statement; ranks are computed over the post-reset ratings
+		_, err = tx.Exec(`
+			WITH old AS (
+				SELECT username, rating, tie_at,
+					LEAST(GREATEST(ROUND(rating + ($2::BIGINT - rating) * $3::NUMERIC / 100)::BIGINT, $4), $5) AS new_rating
+				FROM users WHERE board = $1
+			), reset AS (
+				UPDATE users u
+				SET rating = old.new_rating, tie_at = `+lb.cfg.movedTieSQL("old.tie_at", "old.rating", "old.new_rating")+`
+				FROM old
+				WHERE u.board = $1 AND u.username = old.username
+				RETURNING u.username, old.rating AS old_rating, u.rating AS new_rating, u.tie_at
+			)
+			INSERT INTO rating_history (board, username, old_rating, new_rating, rank, source)
+			SELECT $1, username, old_rating, new_rating, rank, $6
+			FROM (
+				SELECT reset.*, `+lb.cfg.rankWindowSQL("new_rating", "tie_at", "username")+` AS rank FROM reset
+			) ranked
+			WHERE old_rating <> new_rating`,
+			lb.cfg.Name, reset.Target, reset.Factor, lb.cfg.MinRating, lb.cfg.MaxRating, SourceSeason)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if reset.Mode != ResetNone {
+		if err := lb.notifyBulk(tx, SourceSeason); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if reset.Mode != ResetNone {
+		if err := lb.reloadRankCache(); err != nil {
+			return nil, err
+		}
+		lb.feed.publishBulk(SourceSeason)
+	}
+	return &season, nil
+}
+
+func (lb *PostgresStore) ListSeasons() ([]Season, error) {
+	rows, err := lb.db.Query(`
+		SELECT `+seasonColumns+` FROM seasons
+		WHERE board = $1
+		ORDER BY ended_at DESC`, lb.cfg.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seasons := []Season{}
+	for rows.Next() {
+		s, err := scanSeason(rows)
+		if err != nil {
+			return nil, err
+		}
+		seasons = append(seasons, s)
+	}
+	return seasons, rows.Err()
+}
+
+func (lb *PostgresStore) SeasonStandings(id int64, limit, offset int) (*Season, []RankedUser, error) {
+	season, err := scanSeason(lb.db.QueryRow(`
+		SELECT `+seasonColumns+` FROM seasons
+		WHERE board = $1 AND id = $2`, lb.cfg.Name, id))
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrSeasonNotFound
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := lb.db.Query(`
+		SELECT username, rating, rank FROM season_standings
+		WHERE season_id = $1
+		ORDER BY rank ASC, username ASC
+		LIMIT $2 OFFSET $3`, id, limit, offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	users := []RankedUser{}
+	for rows.Next() {
+		var r RankedUser
+		if err := rows.Scan(&r.Username, &r.Rating, &r.Rank); err != nil {
+			return nil, nil, err
+		}
+		users = append(users, r)
+	}
+	return &season, users, rows.Err()
+}
+
+func (lb *PostgresStore) UserSeasons(username string) ([]SeasonFinish, error) {
+	rows, err := lb.db.Query(`
+		SELECT s.id, s.board, s.name, s.started_at, s.ended_at, s.players, s.reset_mode,
+			ss.rating, ss.rank
+		FROM season_standings ss
+		JOIN seasons s ON s.id = ss.season_id
+		WHERE s.board = $1 AND ss.username = $2
+		ORDER BY s.ended_at DESC`, lb.cfg.Name, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	finishes := []SeasonFinish{}
+	for rows.Next() {
+		var f SeasonFinish
+		s := &f.Season
+		err := rows.Scan(&s.ID, &s.Board, &s.Name, &s.StartedAt, &s.EndedAt, &s.Players, &s.ResetMode,
+			&f.Rating, &f.Rank)
+		if err != nil {
+			return nil, err
+		}
+		finishes = append(finishes, f)
+	}
+	return finishes, rows.Err()
+}
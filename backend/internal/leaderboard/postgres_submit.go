@@ -0,0 +1,129 @@
+package leaderboard
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// submitSQL is the SQL score an entry holding rating keeps once score is
+// submitted under policy; the twin of BoardConfig.submitted
+func (c BoardConfig) submitSQL(policy, rating, score string) string {
+	switch policy {
+	case SubmitBest:
+		if c.ascending() {
+			return fmt.Sprintf("LEAST(%s, %s)", rating, score)
+		}
+		return fmt.Sprintf("GREATEST(%s, %s)", rating, score)
+	case SubmitLatest:
+		return score
+	case SubmitSum:
+		return fmt.Sprintf("LEAST(GREATEST(%s::NUMERIC + %s::NUMERIC, %d), %d)::BIGINT", rating, score, c.MinRating, c.MaxRating)
+	default:
+		return rating
+	}
+}
+
+// Submit applies the policy in a single locked UPDATE, so concurrent
+// submissions never lose each other. A user without an entry is inserted.
+func (lb *PostgresStore) Submit(username string, score int64, policy string, source Source) (*SubmitResult, error) {
+	if err := lb.cfg.checkSubmit(policy, score); err != nil {
+		return nil, err
+	}
+
+	lb.writeMu.RLock()
+	defer lb.writeMu.RUnlock()
+
+	tx, err := lb.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	oldRating, newRating, err := lb.submitTx(tx, username, score, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := oldRating == nil || *oldRating != newRating
+	if changed {
+		if err := lb.recordChange(tx, username, oldRating, newRating, source); err != nil {
+			return nil, err
+		}
+		if err := lb.notifyUser(tx, username, oldRating, &newRating, source); err != nil {
+			return nil, err
+		}
+	}
+	tiedAhead, err := lb.tiedAheadTx(tx, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if changed {
+		if oldRating == nil {
+			lb.ranks.add(newRating)
+		} else {
+			lb.ranks.move(*oldRating, newRating)
+		}
+		lb.feed.publishUser(username, oldRating, &newRating, source)
+	}
+	ranked := lb.rankWritten(username, newRating, tiedAhead)
+	return &SubmitResult{RankedUser: *ranked, Previous: oldRating, Changed: changed}, nil
+}
+
+// submitTx applies a submission inside tx, inserting the user if they
+// have no entry, and returns the replaced score (nil if the user was
+// inserted) and the new one
+func (lb *PostgresStore) submitTx(tx *sql.Tx, username string, score int64, policy string) (oldRating *int64, newRating int64, err error) {
+	oldRating, newRating, err = lb.submitExisting(tx, username, score, policy)
+	if err != nil || oldRating != nil {
+		return oldRating, newRating, err
+	}
+
+	if err := lb.cfg.checkRating(score); err != nil {
+		return nil, 0, err
+	}
+	inserted, err := lb.insertTx(tx, username, score)
+	if err != nil || inserted {
+		return nil, score, err
+	}
+	// A concurrent first submission won the insert; apply ours on top
+	oldRating, newRating, err = lb.submitExisting(tx, username, score, policy)
+	if err == nil && oldRating == nil {
+		err = ErrUserNotFound // Deleted again in between
+	}
+	return oldRating, newRating, err
+}
+
+// insertTx inserts a user inside tx, reporting false if they already exist
+func (lb *PostgresStore) insertTx(tx *sql.Tx, username string, rating int64) (bool, error) {
+	res, err := tx.Exec("INSERT INTO users (board, username, rating, tie_at) VALUES ($1, $2, $3, "+lb.cfg.newTieSQL()+") ON CONFLICT DO NOTHING",
+		lb.cfg.Name, username, rating)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// submitExisting applies a submission to the user's entry inside tx and
+// returns the replaced score, or nil if the user has no entry
+func (lb *PostgresStore) submitExisting(tx *sql.Tx, username string, score int64, policy string) (oldRating *int64, newRating int64, err error) {
+	var old int64
+	err = tx.QueryRow(`
+		UPDATE users u SET rating = old.new_rating, tie_at = `+lb.cfg.movedTieSQL("old.tie_at", "old.rating", "old.new_rating")+`
+		FROM (
+			SELECT board, username, rating, tie_at, `+lb.cfg.submitSQL(policy, "rating", "$1::BIGINT")+` AS new_rating
+			FROM users WHERE board = $2 AND username = $3 FOR UPDATE
+		) old
+		WHERE u.board = old.board AND u.username = old.username
+		RETURNING old.rating, u.rating`, score, lb.cfg.Name, username).Scan(&old, &newRating)
+	if err == sql.ErrNoRows {
+		return nil, 0, nil
+	} else if err != nil {
+		return nil, 0, err
+	}
+	return &old, newRating, nil
+}
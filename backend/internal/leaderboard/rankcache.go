@@ -0,0 +1,163 @@
+package leaderboard
+
+import "sync"
+
+// rankCache is an in-process histogram of ratings.
+// It answers "how many users are rated ahead of X" in O(log n) via a
+// ScoreTree, so rank lookups never have to scan the users table.
+type rankCache struct {
+	cfg  BoardConfig
+	mu   sync.RWMutex
+	tree *ScoreTree
+}
+
+func newRankCache(cfg BoardConfig) *rankCache {
+	return &rankCache{
+		cfg:  cfg,
+		tree: cfg.newScoreTree(),
+	}
+}
+
+// load replaces the cache contents with the given rating -> count
+// histogram. Ratings outside the board bounds are kept, since the rows
+// holding them still rank, so the cache matches the database they came from.
+func (c *rankCache) load(hist map[int64]int) {
+	tree := c.cfg.newScoreTree()
+	for rating, n := range hist {
+		if n <= 0 {
+			continue
+		}
+		tree.Add(rating, n)
+	}
+
+	c.mu.Lock()
+	c.tree = tree
+	c.mu.Unlock()
+}
+
+func (c *rankCache) add(rating int64) {
+	c.mu.Lock()
+	c.tree.Add(rating, 1)
+	c.mu.Unlock()
+}
+
+func (c *rankCache) remove(rating int64) {
+	c.mu.Lock()
+	c.tree.Add(rating, -1)
+	c.mu.Unlock()
+}
+
+func (c *rankCache) move(oldRating, newRating int64) {
+	if oldRating == newRating {
+		return
+	}
+	c.mu.Lock()
+	c.tree.Add(oldRating, -1)
+	c.tree.Add(newRating, 1)
+	c.mu.Unlock()
+}
+
+// rank returns the rank of a user at rating under the board's ranking
+// mode. Under ordinal ranking it is the rank of the first user at the
+// rating; callers add the users ahead of theirs in tie-break order.
+func (c *rankCache) rank(rating int64) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.cfg.RankingMode == RankDense {
+		return c.tree.DistinctAhead(rating) + 1
+	}
+	return c.tree.Ahead(rating) + 1
+}
+
+// projectedRank returns what rank will return for newRating once the
+// user's previous rating (nil for a new user) has been moved out of the
+// cache and newRating added
+func (c *rankCache) projectedRank(oldRating *int64, newRating int64) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.cfg.RankingMode == RankDense {
+		above := c.tree.DistinctAhead(newRating)
+		if oldRating != nil && c.cfg.ahead(*oldRating, newRating) && c.tree.CountAt(*oldRating) == 1 {
+			above-- // The user was alone at their old rating
+		}
+		return above + 1
+	}
+
+	above := c.tree.Ahead(newRating)
+	if oldRating != nil && c.cfg.ahead(*oldRating, newRating) {
+		above-- // Don't count the user's own old entry
+	}
+	return above + 1
+}
+
+// ratingAt returns the rating held at rank, i.e. the worst rating that
+// still reaches it
+func (c *rankCache) ratingAt(rank int) (int64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	kth := c.tree.Kth
+	if c.cfg.RankingMode == RankDense {
+		// Dense rank r is held by the r-th best distinct rating
+		kth = c.tree.KthDistinct
+	}
+	if rank < 1 {
+		return 0, ErrRankOutOfRange
+	}
+	rating, ok := kth(rank)
+	if !ok {
+		return 0, ErrRankOutOfRange
+	}
+	return rating, nil
+}
+
+func (c *rankCache) count() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tree.Len()
+}
+
+// histogram returns a snapshot of the non-zero buckets
+func (c *rankCache) histogram() map[int64]int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	hist := make(map[int64]int, c.tree.Distinct())
+	c.tree.Each(func(rating int64, n int) {
+		hist[rating] = n
+	})
+	return hist
+}
+
+func (c *rankCache) stats() LeaderboardStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return treeStats(c.tree)
+}
+
+// treeStats summarizes the ratings in tree
+func treeStats(tree *ScoreTree) LeaderboardStats {
+	stats := LeaderboardStats{TotalUsers: tree.Len(), UniqueRatings: tree.Distinct()}
+	if stats.UniqueRatings == 0 {
+		return stats
+	}
+	best, _ := tree.KthDistinct(1)
+	worst, _ := tree.KthDistinct(stats.UniqueRatings)
+	stats.HighestRating, stats.LowestRating = max(best, worst), min(best, worst)
+	return stats
+}
+
+// sameHistogram reports whether two rating histograms are identical
+func sameHistogram(a, b map[int64]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for rating, n := range a {
+		if b[rating] != n {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package leaderboard
+
+import (
+	"fmt"
+	"time"
+)
+
+// Ranking modes decide the ranks of users with equal ratings
+const (
+	RankCompetition = "competition" // ties share a rank and leave a gap: 1, 1, 3
+	RankDense       = "dense"       // ties share a rank with no gap: 1, 1, 2
+	RankOrdinal     = "ordinal"     // every user has their own rank, in tie-break order
+)
+
+// Tie-breaks order users with equal ratings in every listing, and decide
+// who ranks higher under ordinal ranking
+const (
+	TieBreakUsername = "username" // alphabetical
+	TieBreakAchieved = "achieved" // whoever reached the rating first
+	TieBreakCreated  = "created"  // oldest account first
+)
+
+// Orders decide which way ratings rank
+const (
+	OrderDescending = "desc" // higher is better, e.g. ratings and high scores
+	OrderAscending  = "asc"  // lower is better, e.g. speedrun times
+)
+
+// ascending reports whether lower ratings rank ahead on the board
+func (c BoardConfig) ascending() bool {
+	return c.Order == OrderAscending
+}
+
+// ahead reports whether rating a ranks ahead of rating b
+func (c BoardConfig) ahead(a, b int64) bool {
+	if c.ascending() {
+		return a < b
+	}
+	return a > b
+}
+
+// newScoreTree creates an empty rank index in the board's order
+func (c BoardConfig) newScoreTree() *ScoreTree {
+	return NewScoreTree(c.ascending())
+}
+
+// noTie is the tie key of every user on a username tie-break board, so
+// ties fall through to the username
+var noTie = time.Unix(0, 0).UTC()
+
+// listedBefore reports whether a comes before b in leaderboard order:
+// rating in the board's order, then tie key, then username
+func (c BoardConfig) listedBefore(a, b User) bool {
+	if a.Rating != b.Rating {
+		return c.ahead(a.Rating, b.Rating)
+	}
+	if !a.tie.Equal(b.tie) {
+		return a.tie.Before(b.tie)
+	}
+	return a.Username < b.Username
+}
+
+// rankWalk hands out ranks under a ranking mode to users visited in
+// leaderboard order
+type rankWalk struct {
+	mode string
+	seen int
+	rank int
+	last int64 // rating of the previous user
+}
+
+// next returns the rank of the next user, rated rating
+func (w *rankWalk) next(rating int64) int {
+	w.seen++
+	switch {
+	case w.seen > 1 && w.mode != RankOrdinal && rating == w.last:
+		// Ties share the previous rank
+	case w.mode == RankDense:
+		w.rank++
+	default:
+		w.rank = w.seen
+	}
+	w.last = rating
+	return w.rank
+}
+
+// rankWindowSQL is the window function that ranks rows of a query the way
+// the board ranks users. The rows must have rating, tie_at and username
+// columns under the given names.
+func (c BoardConfig) rankWindowSQL(rating, tie, username string) string {
+	switch c.RankingMode {
+	case RankDense:
+		return fmt.Sprintf("DENSE_RANK() OVER (ORDER BY %s %s)", rating, c.orderSQL())
+	case RankOrdinal:
+		return fmt.Sprintf("ROW_NUMBER() OVER (ORDER BY %s %s, %s ASC, %s ASC)", rating, c.orderSQL(), tie, username)
+	default:
+		return fmt.Sprintf("RANK() OVER (ORDER BY %s %s)", rating, c.orderSQL())
+	}
+}
+
+// orderSQL is the SQL sort direction that lists ratings best first
+func (c BoardConfig) orderSQL() string {
+	if c.ascending() {
+		return "ASC"
+	}
+	return "DESC"
+}
+
+// reverseSQL is the SQL sort direction that lists ratings worst first
+func (c BoardConfig) reverseSQL() string {
+	if c.ascending() {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// behindSQL is the SQL comparison operator matching ratings that rank
+// behind the right-hand side
+func (c BoardConfig) behindSQL() string {
+	if c.ascending() {
+		return ">"
+	}
+	return "<"
+}
+
+// aheadSQL is the SQL comparison operator matching ratings that rank
+// ahead of the right-hand side
+func (c BoardConfig) aheadSQL() string {
+	if c.ascending() {
+		return "<"
+	}
+	return ">"
+}
+
+// bestSQL is the SQL aggregate that picks the best rating of a set
+func (c BoardConfig) bestSQL() string {
+	if c.ascending() {
+		return "MIN"
+	}
+	return "MAX"
+}
+
+// userOrder is leaderboard order over userColumns
+func (c BoardConfig) userOrder() string {
+	return "rating " + c.orderSQL() + ", tie_at ASC, username ASC"
+}
+
+// newTieSQL is the SQL tie key of a user created now
+func (c BoardConfig) newTieSQL() string {
+	if c.TieBreak == TieBreakUsername {
+		return "'epoch'"
+	}
+	return "NOW()"
+}
+
+// movedTieSQL is the SQL tie key of a user whose rating moves from the
+// oldRating to the newRating expression
+func (c BoardConfig) movedTieSQL(tie, oldRating, newRating string) string {
+	if c.TieBreak == TieBreakAchieved {
+		return fmt.Sprintf("CASE WHEN %s <> %s THEN NOW() ELSE %s END", newRating, oldRating, tie)
+	}
+	return tie
+}
@@ -0,0 +1,210 @@
+package leaderboard
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+var ErrNotBatched = errors.New("board does not use rating periods")
+
+// Rating systems a board can use for match results
+const (
+	RatingSystemElo     = "elo"
+	RatingSystemGlicko2 = "glicko2"
+)
+
+// DefaultKFactor is the Elo K-factor boards get unless configured otherwise
+const DefaultKFactor = 32
+
+// Glicko-2 defaults for players who have never played
+const (
+	DefaultDeviation  = 350.0
+	DefaultVolatility = 0.06
+	DefaultTau        = 0.5 // constrains volatility change; 0.3-1.2 is sensible
+)
+
+// PlayerRating is a player's full rating state. Elo only uses Rating;
+// Glicko-2 also tracks how uncertain (Deviation) and how erratic
+// (Volatility) that rating is.
+type PlayerRating struct {
+	Rating     float64 `json:"rating"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+}
+
+// Outcome is one game from a player's point of view
+type Outcome struct {
+	Opponent PlayerRating
+	Score    float64 // 1 for a win, 0.5 for a draw, 0 for a loss
+}
+
+// RatingSystem turns game outcomes into new ratings
+type RatingSystem interface {
+	Name() string
+	// Batched systems queue games and rate them when a rating period closes
+	Batched() bool
+	// Rate returns p after the given games. For batched systems outcomes are
+	// all of p's games in the period, possibly none.
+	Rate(p PlayerRating, outcomes []Outcome) PlayerRating
+}
+
+// RatedPlayer is one player's rating change when a rating period closes
+type RatedPlayer struct {
+	Username   string  `json:"username"`
+	Games      int     `json:"games"`
+	OldRating  int64   `json:"old_rating"`
+	NewRating  int64   `json:"new_rating"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+}
+
+// RatingPeriod summarizes a closed rating period. Players lists only those
+// who played; everyone else's deviation grew.
+type RatingPeriod struct {
+	Board    string        `json:"board"`
+	Matches  int           `json:"matches"`
+	Players  []RatedPlayer `json:"players"`
+	ClosedAt time.Time     `json:"closed_at"`
+}
+
+// RatingPeriodStore is implemented by stores that can queue matches for
+// batched rating systems
+type RatingPeriodStore interface {
+	// CloseRatingPeriod rates every pending match of the board at once
+	CloseRatingPeriod() (*RatingPeriod, error)
+}
+
+var _ RatingPeriodStore = (*PostgresStore)(nil)
+
+// ratingSystem returns the rating system configured for the board
+func (c BoardConfig) ratingSystem() RatingSystem {
+	if c.RatingSystem == RatingSystemGlicko2 {
+		return Glicko2{Tau: DefaultTau}
+	}
+	return Elo{K: c.KFactor}
+}
+
+// Elo rates games with the classic logistic Elo formula
+type Elo struct {
+	K float64 // largest possible rating change per game
+}
+
+// ExpectedScore is the probability that a player rated a beats one rated b
+func ExpectedScore(a, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/400))
+}
+
+func (e Elo) Name() string  { return RatingSystemElo }
+func (e Elo) Batched() bool { return false }
+
+func (e Elo) Rate(p PlayerRating, outcomes []Outcome) PlayerRating {
+	for _, o := range outcomes {
+		p.Rating += e.K * (o.Score - ExpectedScore(p.Rating, o.Opponent.Rating))
+	}
+	return p
+}
+
+// glicko2Scale converts between the Glicko and Glicko-2 rating scales
+const glicko2Scale = 173.7178
+
+// Glicko2 implements Mark Glickman's Glicko-2 system
+// (http://www.glicko.net/glicko/glicko2.pdf). Games are rated in batches,
+// one rating period at a time; a player who sits a period out keeps their
+// rating but their deviation grows.
+type Glicko2 struct {
+	Tau float64
+}
+
+func (g Glicko2) Name() string  { return RatingSystemGlicko2 }
+func (g Glicko2) Batched() bool { return true }
+
+func (g Glicko2) Rate(p PlayerRating, outcomes []Outcome) PlayerRating {
+	if p.Deviation <= 0 {
+		p.Deviation = DefaultDeviation
+	}
+	if p.Volatility <= 0 {
+		p.Volatility = DefaultVolatility
+	}
+
+	// Step 2: convert to the Glicko-2 scale
+	mu := (p.Rating - 1500) / glicko2Scale
+	phi := p.Deviation / glicko2Scale
+	sigma := p.Volatility
+
+	if len(outcomes) == 0 {
+		// Step 6 only: uncertainty grows while inactive
+		phi = math.Sqrt(phi*phi + sigma*sigma)
+		p.Deviation = math.Min(phi*glicko2Scale, DefaultDeviation)
+		return p
+	}
+
+	// Steps 3 and 4: estimated variance v and improvement delta
+	var vInv, sum float64
+	for _, o := range outcomes {
+		muJ := (o.Opponent.Rating - 1500) / glicko2Scale
+		phiJ := o.Opponent.Deviation / glicko2Scale
+		gJ := glickoG(phiJ)
+		e := 1 / (1 + math.Exp(-gJ*(mu-muJ)))
+		vInv += gJ * gJ * e * (1 - e)
+		sum += gJ * (o.Score - e)
+	}
+	v := 1 / vInv
+	delta := v * sum
+
+	// Step 5: new volatility
+	sigma = g.volatility(phi, sigma, v, delta)
+
+	// Steps 6 and 7: new deviation and rating
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * sum
+
+	// Step 8: back to the Glicko scale
+	return PlayerRating{
+		Rating:     mu*glicko2Scale + 1500,
+		Deviation:  math.Min(phi*glicko2Scale, DefaultDeviation),
+		Volatility: sigma,
+	}
+}
+
+func glickoG(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+// volatility solves step 5 with the Illinois algorithm
+func (g Glicko2) volatility(phi, sigma, v, delta float64) float64 {
+	const epsilon = 0.000001
+
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(g.Tau*g.Tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*g.Tau) < 0 {
+			k++
+		}
+		B = a - k*g.Tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
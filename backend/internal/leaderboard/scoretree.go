@@ -0,0 +1,213 @@
+package leaderboard
+
+import "math/rand"
+
+// ScoreTree is an order-statistic tree over the scores users hold.
+// It is a treap with one node per distinct score, so its size depends
+// on how many scores are in use and never on the range they span.
+// Scores are kept in leaderboard order, best first.
+type ScoreTree struct {
+	root      *scoreNode
+	ascending bool // lower scores rank ahead
+}
+
+type scoreNode struct {
+	score       int64
+	count       int    // users at score
+	users       int    // users in the subtree
+	scores      int    // distinct scores in the subtree
+	priority    uint64 // heap order keeps the tree balanced in expectation
+	left, right *scoreNode
+}
+
+// NewScoreTree creates an empty tree. Higher scores rank ahead unless
+// ascending is set.
+func NewScoreTree(ascending bool) *ScoreTree {
+	return &ScoreTree{ascending: ascending}
+}
+
+// ahead reports whether score a ranks ahead of score b
+func (t *ScoreTree) ahead(a, b int64) bool {
+	if t.ascending {
+		return a < b
+	}
+	return a > b
+}
+
+func (n *scoreNode) fix() {
+	n.users, n.scores = n.count, 1
+	for _, c := range [2]*scoreNode{n.left, n.right} {
+		if c != nil {
+			n.users += c.users
+			n.scores += c.scores
+		}
+	}
+}
+
+func usersIn(n *scoreNode) int {
+	if n == nil {
+		return 0
+	}
+	return n.users
+}
+
+func scoresIn(n *scoreNode) int {
+	if n == nil {
+		return 0
+	}
+	return n.scores
+}
+
+// split cuts n into the scores ahead of score (and score itself when
+// inclusive) and the rest
+func (t *ScoreTree) split(n *scoreNode, score int64, inclusive bool) (*scoreNode, *scoreNode) {
+	if n == nil {
+		return nil, nil
+	}
+	if t.ahead(n.score, score) || (inclusive && n.score == score) {
+		var rest *scoreNode
+		n.right, rest = t.split(n.right, score, inclusive)
+		n.fix()
+		return n, rest
+	}
+	var front *scoreNode
+	front, n.left = t.split(n.left, score, inclusive)
+	n.fix()
+	return front, n
+}
+
+// merge joins two trees where every score in a ranks ahead of those in b
+func merge(a, b *scoreNode) *scoreNode {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.priority > b.priority {
+		a.right = merge(a.right, b)
+		a.fix()
+		return a
+	}
+	b.left = merge(a, b.left)
+	b.fix()
+	return b
+}
+
+// Add adds delta users at score, dropping the score once nobody holds it
+func (t *ScoreTree) Add(score int64, delta int) {
+	front, rest := t.split(t.root, score, false)
+	at, back := t.split(rest, score, true)
+	if at == nil {
+		at = &scoreNode{score: score, priority: rand.Uint64()}
+	}
+	at.count += delta
+	if at.count > 0 {
+		at.fix()
+	} else {
+		at = nil
+	}
+	t.root = merge(merge(front, at), back)
+}
+
+// Len returns the number of users in the tree
+func (t *ScoreTree) Len() int {
+	return usersIn(t.root)
+}
+
+// Distinct returns the number of distinct scores in the tree
+func (t *ScoreTree) Distinct() int {
+	return scoresIn(t.root)
+}
+
+// CountAt returns the number of users at score
+func (t *ScoreTree) CountAt(score int64) int {
+	for n := t.root; n != nil; {
+		switch {
+		case n.score == score:
+			return n.count
+		case t.ahead(score, n.score):
+			n = n.left
+		default:
+			n = n.right
+		}
+	}
+	return 0
+}
+
+// Ahead returns the number of users whose score ranks ahead of score
+func (t *ScoreTree) Ahead(score int64) int {
+	ahead := 0
+	for n := t.root; n != nil; {
+		if t.ahead(n.score, score) {
+			ahead += usersIn(n.left) + n.count
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	return ahead
+}
+
+// DistinctAhead returns the number of distinct scores ranking ahead of score
+func (t *ScoreTree) DistinctAhead(score int64) int {
+	ahead := 0
+	for n := t.root; n != nil; {
+		if t.ahead(n.score, score) {
+			ahead += scoresIn(n.left) + 1
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	return ahead
+}
+
+// Kth returns the score of the k-th user in leaderboard order (1-indexed).
+// ok is false if there are fewer than k users.
+func (t *ScoreTree) Kth(k int) (score int64, ok bool) {
+	for n := t.root; n != nil; {
+		switch left := usersIn(n.left); {
+		case k <= left:
+			n = n.left
+		case k <= left+n.count:
+			return n.score, true
+		default:
+			k -= left + n.count
+			n = n.right
+		}
+	}
+	return 0, false
+}
+
+// KthDistinct returns the k-th distinct score in leaderboard order
+// (1-indexed). ok is false if there are fewer than k distinct scores.
+func (t *ScoreTree) KthDistinct(k int) (score int64, ok bool) {
+	for n := t.root; n != nil; {
+		switch left := scoresIn(n.left); {
+		case k <= left:
+			n = n.left
+		case k == left+1:
+			return n.score, true
+		default:
+			k -= left + 1
+			n = n.right
+		}
+	}
+	return 0, false
+}
+
+// Each calls fn for every distinct score in leaderboard order with the
+// number of users holding it
+func (t *ScoreTree) Each(fn func(score int64, count int)) {
+	var walk func(n *scoreNode)
+	walk = func(n *scoreNode) {
+		if n == nil {
+			return
+		}
+		walk(n.left)
+		fn(n.score, n.count)
+		walk(n.right)
+	}
+	walk(t.root)
+}
@@ -0,0 +1,75 @@
+package leaderboard
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrSeasonNotFound = errors.New("season not found")
+	ErrSeasonExists   = errors.New("season already exists")
+	ErrInvalidReset   = errors.New("invalid season reset")
+)
+
+// Season reset modes, applied to the live board once standings are archived
+const (
+	ResetNone  = "none"  // ratings carry over unchanged
+	ResetFresh = "fresh" // the board is emptied
+	ResetSoft  = "soft"  // ratings are pulled toward Target by Factor percent
+)
+
+// SeasonReset controls how ratings carry over into the next season.
+// A soft reset with Factor 100 puts everyone on Target.
+type SeasonReset struct {
+	Mode   string  `json:"mode"`
+	Target int64   `json:"target"`
+	Factor float64 `json:"factor"`
+}
+
+// Validate checks the reset against the board's rating bounds
+func (r SeasonReset) Validate(cfg BoardConfig) error {
+	switch r.Mode {
+	case ResetNone, ResetFresh:
+		return nil
+	case ResetSoft:
+		if err := cfg.checkRating(r.Target); err != nil {
+			return fmt.Errorf("%w: target %v", ErrInvalidReset, err)
+		}
+		if r.Factor <= 0 || r.Factor > 100 {
+			return fmt.Errorf("%w: factor must be in (0, 100]", ErrInvalidReset)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidReset, r.Mode)
+	}
+}
+
+// Season is a closed season of a board
+type Season struct {
+	ID        int64     `json:"id"`
+	Board     string    `json:"board"`
+	Name      string    `json:"name"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	Players   int       `json:"players"`
+	ResetMode string    `json:"reset_mode"`
+}
+
+// SeasonFinish is where a user finished in a past season
+type SeasonFinish struct {
+	Season Season `json:"season"`
+	Rating int64  `json:"rating"`
+	Rank   int    `json:"rank"`
+}
+
+// SeasonStore is implemented by stores that archive season standings
+type SeasonStore interface {
+	// EndSeason freezes the current standings under name and applies reset
+	EndSeason(name string, reset SeasonReset) (*Season, error)
+	ListSeasons() ([]Season, error)
+	SeasonStandings(id int64, limit, offset int) (*Season, []RankedUser, error)
+	UserSeasons(username string) ([]SeasonFinish, error)
+}
+
+var _ SeasonStore = (*PostgresStore)(nil)
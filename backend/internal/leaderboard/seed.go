@@ -0,0 +1,218 @@
+package leaderboard
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+var ErrInvalidSeed = errors.New("invalid seed options")
+
+// Seed rating distributions
+const (
+	DistUniform  = "uniform"
+	DistNormal   = "normal"    // around Mean with StdDev, clamped to the bounds
+	DistPowerLaw = "power_law" // most users near the bottom, a long tail of leaders
+)
+
+// DefaultUsernamePattern is the pattern used when SeedOptions leaves it empty
+const DefaultUsernamePattern = "{name}_{n}"
+
+// DefaultPowerLawExponent is the power-law exponent used when SeedOptions
+// leaves it zero
+const DefaultPowerLawExponent = 1.5
+
+// SeedOptions configures a seed. The same options, including RandomSeed,
+// always generate the same users in the same order.
+type SeedOptions struct {
+	Count int
+	Clear bool
+	// RandomSeed seeds the generator; 0 picks one from the clock, which
+	// is reported back in SeedResult so the run can be repeated
+	RandomSeed   int64
+	Distribution string
+	// Mean and StdDev shape DistNormal. They default to the middle of the
+	// board bounds and a sixth of their range.
+	Mean   *float64
+	StdDev float64
+	// Exponent shapes DistPowerLaw; larger values thin out the tail
+	Exponent float64
+	// UsernamePattern builds usernames from {i}, the 1-based position in
+	// the seed, {name}, a fake username, and {n}, a number up to 99999
+	UsernamePattern string
+}
+
+// SeedResult reports what a seed actually did. Generated users whose
+// username was already taken are skipped, not retried.
+type SeedResult struct {
+	RandomSeed int64 `json:"seed"`
+	Requested  int   `json:"requested"`
+	Created    int   `json:"created"`
+	Skipped    int   `json:"skipped"`
+}
+
+// seedDefaults validates opts against the board and fills in defaults
+func (c BoardConfig) seedDefaults(opts SeedOptions) (SeedOptions, error) {
+	if opts.Count < 0 {
+		return opts, fmt.Errorf("%w: count must not be negative", ErrInvalidSeed)
+	}
+	if opts.RandomSeed == 0 {
+		opts.RandomSeed = time.Now().UnixNano()
+	}
+	if opts.UsernamePattern == "" {
+		opts.UsernamePattern = DefaultUsernamePattern
+	} else if !strings.Contains(opts.UsernamePattern, "{i}") && !strings.Contains(opts.UsernamePattern, "{name}") &&
+		!strings.Contains(opts.UsernamePattern, "{n}") {
+		return opts, fmt.Errorf("%w: username_pattern must contain {i}, {name} or {n}", ErrInvalidSeed)
+	}
+
+	switch opts.Distribution {
+	case "":
+		opts.Distribution = DistUniform
+	case DistUniform:
+	case DistNormal:
+		if opts.Mean == nil {
+			mean := float64(c.MinRating)/2 + float64(c.MaxRating)/2
+			opts.Mean = &mean
+		}
+		if opts.StdDev < 0 {
+			return opts, fmt.Errorf("%w: stddev must not be negative", ErrInvalidSeed)
+		} else if opts.StdDev == 0 {
+			opts.StdDev = (float64(c.MaxRating) - float64(c.MinRating)) / 6
+		}
+	case DistPowerLaw:
+		if opts.Exponent < 0 {
+			return opts, fmt.Errorf("%w: exponent must be positive", ErrInvalidSeed)
+		} else if opts.Exponent == 0 {
+			opts.Exponent = DefaultPowerLawExponent
+		}
+	default:
+		return opts, fmt.Errorf("%w: distribution must be %q, %q or %q", ErrInvalidSeed, DistUniform, DistNormal, DistPowerLaw)
+	}
+	return opts, nil
+}
+
+// seeder generates the users of a seed from its own generator, so
+// concurrent seeds never disturb each other's sequence
+type seeder struct {
+	cfg   BoardConfig
+	opts  SeedOptions
+	faker *gofakeit.Faker
+}
+
+// newSeeder validates opts and returns a seeder for them
+func (c BoardConfig) newSeeder(opts SeedOptions) (*seeder, error) {
+	opts, err := c.seedDefaults(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &seeder{cfg: c, opts: opts, faker: gofakeit.New(opts.RandomSeed)}, nil
+}
+
+// result starts the report of the seed
+func (s *seeder) result() *SeedResult {
+	return &SeedResult{RandomSeed: s.opts.RandomSeed, Requested: s.opts.Count}
+}
+
+// user generates the i-th (0-based) user of the seed
+func (s *seeder) user(i int) (string, int64) {
+	username := s.username(i)
+	return username, s.rating()
+}
+
+// username fills in the username pattern
+func (s *seeder) username(i int) string {
+	var b strings.Builder
+	pattern := s.opts.UsernamePattern
+	for pattern != "" {
+		switch {
+		case strings.HasPrefix(pattern, "{i}"):
+			b.WriteString(strconv.Itoa(i + 1))
+			pattern = pattern[3:]
+		case strings.HasPrefix(pattern, "{n}"):
+			b.WriteString(strconv.Itoa(s.faker.Number(1, 99999)))
+			pattern = pattern[3:]
+		case strings.HasPrefix(pattern, "{name}"):
+			b.WriteString(s.faker.Username())
+			pattern = pattern[6:]
+		default:
+			b.WriteByte(pattern[0])
+			pattern = pattern[1:]
+		}
+	}
+	username := b.String()
+	if len(username) > MaxUsernameLength {
+		username = username[:MaxUsernameLength]
+	}
+	return username
+}
+
+// rating draws an in-bounds rating from the distribution
+func (s *seeder) rating() int64 {
+	lo, hi := float64(s.cfg.MinRating), float64(s.cfg.MaxRating)
+	r := s.faker.Rand
+
+	switch s.opts.Distribution {
+	case DistNormal:
+		return s.clamp(r.NormFloat64()*s.opts.StdDev + *s.opts.Mean)
+	case DistPowerLaw:
+		// Inverse CDF of a Pareto distribution bounded to [1, span+1],
+		// measured from the losing end of the board
+		a := s.opts.Exponent
+		span := hi - lo + 1
+		x := math.Pow(1-r.Float64()*(1-math.Pow(span, -a)), -1/a) - 1
+		if s.cfg.ascending() {
+			return s.clamp(hi - x)
+		}
+		return s.clamp(lo + x)
+	default:
+		return s.cfg.MinRating + r.Int63n(s.cfg.MaxRating-s.cfg.MinRating+1)
+	}
+}
+
+// clamp rounds v to the nearest in-bounds rating
+func (s *seeder) clamp(v float64) int64 {
+	switch {
+	case math.IsNaN(v) || v <= float64(s.cfg.MinRating):
+		return s.cfg.MinRating
+	case v >= float64(s.cfg.MaxRating):
+		return s.cfg.MaxRating
+	default:
+		return int64(math.Round(v))
+	}
+}
+
+// Seed generates opts.Count users, skipping usernames already taken
+func (lb *Leaderboard) Seed(opts SeedOptions) (*SeedResult, error) {
+	s, err := lb.cfg.newSeeder(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	lb.mu.Lock()
+	if s.opts.Clear {
+		lb.users = make(map[string]int64)
+		lb.ties = make(map[string]time.Time)
+		lb.byRating = make(map[int64]map[string]struct{})
+		lb.scores = lb.cfg.newScoreTree()
+	}
+	result := s.result()
+	for i := 0; i < s.opts.Count; i++ {
+		username, rating := s.user(i)
+		if _, ok := lb.users[username]; ok {
+			result.Skipped++
+			continue
+		}
+		lb.insert(username, rating)
+		result.Created++
+	}
+	lb.mu.Unlock()
+
+	lb.feed.publishBulk(SourceSeed)
+	return result, nil
+}
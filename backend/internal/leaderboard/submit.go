@@ -0,0 +1,87 @@
+package leaderboard
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidPolicy = errors.New("invalid submit policy")
+
+// Submit policies decide how a submitted score combines with the stored one.
+// A user's first submission creates their entry under every policy.
+const (
+	SubmitBest   = "best"   // keep whichever score ranks ahead
+	SubmitLatest = "latest" // always replace
+	SubmitSum    = "sum"    // add to the stored score, clamped to the board bounds
+	SubmitFirst  = "first"  // never replace
+)
+
+// SubmitResult is the outcome of a score submission
+type SubmitResult struct {
+	RankedUser
+	// Previous is the score the submission replaced, nil if it created
+	// the entry
+	Previous *int64 `json:"previous"`
+	// Changed reports whether the entry was created or its score moved
+	Changed bool `json:"changed"`
+}
+
+// checkSubmit validates a submission of score under policy. Scores are
+// checked against the board bounds, except that sum submissions to an
+// existing entry are deltas and saturate instead.
+func (c BoardConfig) checkSubmit(policy string, score int64) error {
+	switch policy {
+	case SubmitBest, SubmitLatest, SubmitFirst:
+		return c.checkRating(score)
+	case SubmitSum:
+		return nil
+	default:
+		return fmt.Errorf("%w: policy must be %q, %q, %q or %q", ErrInvalidPolicy, SubmitBest, SubmitLatest, SubmitSum, SubmitFirst)
+	}
+}
+
+// submitted returns the score an entry holding old keeps once score is
+// submitted under policy
+func (c BoardConfig) submitted(policy string, old, score int64) int64 {
+	switch policy {
+	case SubmitBest:
+		if c.ahead(score, old) {
+			return score
+		}
+		return old
+	case SubmitLatest:
+		return score
+	case SubmitSum:
+		return addClamped(old, score, c.MinRating, c.MaxRating)
+	default:
+		return old
+	}
+}
+
+// Submit records a score for username under policy, creating the entry
+// if the user has none
+func (lb *Leaderboard) Submit(username string, score int64, policy string, source Source) (*SubmitResult, error) {
+	if err := lb.cfg.checkSubmit(policy, score); err != nil {
+		return nil, err
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	old, ok := lb.users[username]
+	if !ok {
+		if err := lb.cfg.checkRating(score); err != nil {
+			return nil, err
+		}
+		lb.insert(username, score)
+		lb.feed.publishUser(username, nil, &score, source)
+		return &SubmitResult{RankedUser: *lb.ranked(username), Changed: true}, nil
+	}
+
+	rating := lb.cfg.submitted(policy, old, score)
+	if rating != old {
+		lb.move(username, rating)
+		lb.feed.publishUser(username, &old, &rating, source)
+	}
+	return &SubmitResult{RankedUser: *lb.ranked(username), Previous: &old, Changed: rating != old}, nil
+}
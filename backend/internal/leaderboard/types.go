@@ -1,16 +1,24 @@
 package leaderboard
 
+import (
+	"math"
+	"time"
+)
+
+// Rating bounds of the default board
 const (
-	MinRating    = 100
-	MaxRating    = 5000
-	RatingRange  = MaxRating - MinRating + 1 // 4901
+	MinRating   = 100
+	MaxRating   = 5000
+	RatingRange = MaxRating - MinRating + 1 // 4901
 )
 
 // User represents a player in the leaderboard
 type User struct {
 	Username string `json:"username"`
-	Rating   int    `json:"rating"`
+	Rating   int64  `json:"rating"`
 	// Optional metadata could go here
+
+	tie time.Time // tie-break key; see BoardConfig.TieBreak
 }
 
 // RankedUser includes computed rank for API responses
@@ -21,8 +29,79 @@ type RankedUser struct {
 
 // LeaderboardStats for monitoring
 type LeaderboardStats struct {
-	TotalUsers    int `json:"total_users"`
-	UniqueRatings int `json:"unique_ratings"`
-	HighestRating int `json:"highest_rating"`
-	LowestRating  int `json:"lowest_rating"`
+	TotalUsers    int   `json:"total_users"`
+	UniqueRatings int   `json:"unique_ratings"`
+	HighestRating int64 `json:"highest_rating"`
+	LowestRating  int64 `json:"lowest_rating"`
+}
+
+// Store is the storage engine for a single board.
+// Implemented by the in-memory Leaderboard and by PostgresStore.
+type Store interface {
+	Config() BoardConfig
+	// Changes is the board's in-process change feed, published to by
+	// every write
+	Changes() *Feed
+	AddUser(username string, rating int64) error
+	UpdateRating(username string, newRating int64) error
+	SetRating(username string, newRating int64, source Source) error
+	// IncrementRating atomically moves a rating by delta, clamped to the
+	// board bounds, and returns the user's new rating and rank
+	IncrementRating(username string, delta int64, source Source) (*RankedUser, error)
+	// Submit records a score under a submit policy in one atomic step,
+	// creating the user's entry if they have none
+	Submit(username string, score int64, policy string, source Source) (*SubmitResult, error)
+	// BulkWrite applies many users in batches under a bulk mode and
+	// reports each row's outcome; invalid rows do not stop the others
+	BulkWrite(rows []BulkRow, mode string) ([]BulkResult, error)
+	// PlanBulk reports what BulkWrite would do without writing
+	PlanBulk(rows []BulkRow, mode string) ([]BulkResult, error)
+	// InsertAll creates every row at once, or none of them and returns
+	// ErrImportConflict if any row is invalid or its user exists
+	InsertAll(rows []BulkRow) ([]BulkResult, error)
+	DeleteUser(username string) error
+	GetUserRank(username string) (*RankedUser, error)
+	RatingAtRank(rank int) (int64, error)
+	GetTopN(limit, offset int) []RankedUser
+	// GetPage returns limit users after (or before) cursor without
+	// scanning the users ahead of it
+	GetPage(cursor Cursor, limit int) (*Page, error)
+	// GetAround returns up to radius users either side of username in
+	// leaderboard order, with the user in the middle
+	GetAround(username string, radius int) ([]RankedUser, error)
+	SearchUsers(query string, limit int) []RankedUser
+	// Export calls fn for every user in leaderboard order, stopping at
+	// the first error fn returns
+	Export(fn func(RankedUser) error) error
+	GetStats() LeaderboardStats
+	Count() int
+	// Seed generates users as opts describes and reports how many were
+	// actually created
+	Seed(opts SeedOptions) (*SeedResult, error)
 }
+
+// RankForPercentile converts a percentile (the share of users at or below
+// a rank, as reported for users) into the lowest rank that still reaches it.
+// Returns 0 if total is 0.
+func RankForPercentile(total int, percentile float64) int {
+	if total <= 0 {
+		return 0
+	}
+	// Inverse of percentile = 100 * (total - rank + 1) / total.
+	// The epsilon keeps exact hits from rounding down a rank.
+	rank := int(math.Floor(float64(total) + 1 - percentile*float64(total)/100 + 1e-9))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > total {
+		rank = total
+	}
+	return rank
+}
+
+var (
+	_ Store  = (*Leaderboard)(nil)
+	_ Store  = (*PostgresStore)(nil)
+	_ Boards = (*MemoryBoards)(nil)
+	_ Boards = (*PostgresBoards)(nil)
+)
@@ -11,15 +11,16 @@ type Config struct {
 	UpdatesPerSecond int
 	Duration         time.Duration
 	RatingChangeMax  int
+	Seed             int64 // 0 picks one from the clock
 }
 
 type Simulator struct {
-	lb        *leaderboard.Leaderboard
+	lb        leaderboard.Store
 	isRunning bool
 	stopChan  chan struct{}
 }
 
-func NewSimulator(lb *leaderboard.Leaderboard) *Simulator {
+func NewSimulator(lb leaderboard.Store) *Simulator {
 	return &Simulator{
 		lb:       lb,
 		stopChan: make(chan struct{}),
@@ -77,7 +78,11 @@ func (s *Simulator) runLoop(cfg Config) {
 	// For this assignment, let's modify the Leaderboard to support "GetRandomUser" or just
 	// grab the top 1000 and shuffle them around.
 
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	r := rand.New(rand.NewSource(seed))
 
 	for {
 		select {
@@ -101,19 +106,10 @@ func (s *Simulator) runLoop(cfg Config) {
 
 			target := topUsers[r.Intn(len(topUsers))]
 
-			// Random change
+			// Random change, applied relative to whatever the rating is by
+			// now so concurrent writers are not overwritten
 			delta := r.Intn(cfg.RatingChangeMax*2) - cfg.RatingChangeMax
-			newRating := target.Rating + delta
-
-			// Clamp
-			if newRating < leaderboard.MinRating {
-				newRating = leaderboard.MinRating
-			}
-			if newRating > leaderboard.MaxRating {
-				newRating = leaderboard.MaxRating
-			}
-
-			s.lb.UpdateRating(target.Username, newRating)
+			s.lb.IncrementRating(target.Username, int64(delta), leaderboard.SourceSimulator)
 		}
 	}
 }
@@ -19,12 +19,10 @@ export function useLeaderboard() {
 
   return useInfiniteQuery({
     queryKey: ['leaderboard'],
-    queryFn: ({ pageParam = 0 }) => leaderboardApi.getLeaderboard(50, pageParam as number),
-    initialPageParam: 0,
-    getNextPageParam: (lastPage) =>
-      lastPage.pagination.has_more
-        ? lastPage.pagination.offset + lastPage.pagination.limit
-        : undefined,
+    queryFn: ({ pageParam }) => leaderboardApi.getLeaderboard(50, pageParam),
+    initialPageParam: undefined as string | undefined,
+    // Cursors keep deep pages as cheap as the first one
+    getNextPageParam: (lastPage) => lastPage.pagination.next_cursor,
     // Poll every 5s, but only if screen is focused (smart polling)
     refetchInterval: 5000,
     refetchIntervalInBackground: false, // PAUSE polling when app is in background to save battery
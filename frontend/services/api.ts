@@ -8,8 +8,9 @@ const api = axios.create({
 });
 
 export const leaderboardApi = {
-  getLeaderboard: async (limit = 50, offset = 0): Promise<LeaderboardResponse> => {
-    const { data } = await api.get('/leaderboard', { params: { limit, offset } });
+  // Pass the previous page's next_cursor to fetch the following page
+  getLeaderboard: async (limit = 50, cursor?: string): Promise<LeaderboardResponse> => {
+    const { data } = await api.get('/leaderboard', { params: cursor ? { limit, cursor } : { limit } });
     return data;
   },
 
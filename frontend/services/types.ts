@@ -7,10 +7,12 @@ export interface User {
 export interface LeaderboardResponse {
   users: User[];
   pagination: {
-    offset: number;
+    offset?: number; // omitted from pages fetched by cursor
     limit: number;
     total: number;
     has_more: boolean;
+    next_cursor?: string;
+    prev_cursor?: string;
   };
 }
 